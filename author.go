@@ -0,0 +1,94 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WithClock sets the clock used for the timestamps of authored changes.
+func WithClock(clock func() time.Time) DocumentOption {
+	return func(d *Document) {
+		d.clock = clock
+	}
+}
+
+// Change edits a draft of the document like PigeonJS `Pigeon.change`. The draft
+// is the unmarshalled document and can be modified or replaced by fn. The
+// resulting change is applied to the document and returned ready to broadcast.
+func (d *Document) Change(clientID string, fn func(draft *any) error) (Change, error) {
+	var draft any
+	if err := json.Unmarshal(d.JSON(), &draft); err != nil {
+		return Change{}, fmt.Errorf("change error: %s", err.Error())
+	}
+
+	if err := fn(&draft); err != nil {
+		return Change{}, err
+	}
+
+	raw, err := json.Marshal(draft)
+	if err != nil {
+		return Change{}, fmt.Errorf("change error: %s", err.Error())
+	}
+
+	return d.Commit(clientID, raw)
+}
+
+// Commit creates a change from the current document to raw. The change gets a
+// timestamp, the next sequence number of the client and a new change id. It is
+// applied to the document and returned ready to broadcast.
+func (d *Document) Commit(clientID string, raw []byte) (Change, error) {
+	operations, err := diff(d.JSON(), raw, d.identifiers)
+	if err != nil {
+		return Change{}, fmt.Errorf("change error: %s", err.Error())
+	}
+
+	change := d.stamp(clientID, operations)
+	if err := d.applyOwnChange(&change); err != nil {
+		return Change{}, err
+	}
+
+	return change, nil
+}
+
+// stamp creates a change with a timestamp, the next sequence number of the client and a new change id.
+func (d *Document) stamp(clientID string, operations []Operation) Change {
+	timestamp := d.clock().UnixMilli()
+	if d.hlc != nil {
+		timestamp = d.hlc.Now()
+	}
+
+	return Change{
+		Diff:            operations,
+		TimestampMillis: timestamp,
+		ClientID:        clientID,
+		Seq:             d.nextSeq(clientID),
+		ChangeID:        uuid.NewString(),
+	}
+}
+
+// applyOwnChange applies a stamped change, counts the sequence number of the
+// client and sets the checksum of the change.
+func (d *Document) applyOwnChange(change *Change) error {
+	if err := d.ApplyChange(*change); err != nil {
+		return err
+	}
+	d.stampChecksum(change)
+
+	d.seqs[change.ClientID] = change.Seq
+	return nil
+}
+
+// nextSeq returns the next sequence number of a client.
+func (d *Document) nextSeq(clientID string) int {
+	seq := d.seqs[clientID]
+	for _, change := range d.history {
+		if change.ClientID == clientID && change.Seq > seq {
+			seq = change.Seq
+		}
+	}
+
+	return seq + 1
+}
@@ -0,0 +1,83 @@
+package pigeongo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChange(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1000)
+	doc, err := NewDocument([]byte(`{"name":"Philipp","cards":[{"id":"card1","text":"foo"}]}`), WithClock(func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}))
+	assert.Nil(t, err)
+
+	change, err := doc.Change("client1", func(draft *any) error {
+		data := (*draft).(map[string]any)
+		data["name"] = "Phil"
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Phil"}`, string(doc.JSON()))
+	assert.Equal(t, "client1", change.ClientID)
+	assert.Equal(t, 1, change.Seq)
+	assert.Equal(t, int64(1001), change.TimestampMillis)
+	assert.Nil(t, uuid.Validate(change.ChangeID))
+	assert.Equal(t, []Operation{
+		{
+			Op:    "replace",
+			Path:  "/name",
+			Value: rawMessage(`"Phil"`),
+			Prev:  rawMessage(`"Philipp"`),
+		},
+	}, change.Diff)
+	assert.Equal(t, change, doc.History()[1])
+
+	change, err = doc.Commit("client1", []byte(`{"name":"Phil","cards":[{"id":"card1","text":"bar"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, change.Seq)
+	assert.Equal(t, int64(1002), change.TimestampMillis)
+	assert.Equal(t, "/cards/[card1]/text", change.Diff[0].Path)
+
+	// sequence numbers are counted per client
+	change, err = doc.Commit("client2", []byte(`{"name":"Hans","cards":[{"id":"card1","text":"bar"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, change.Seq)
+
+	// sequence numbers of received changes are respected
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{},
+		TimestampMillis: 1003,
+		ClientID:        "client2",
+		Seq:             10,
+		ChangeID:        "remote",
+	}))
+	change, err = doc.Commit("client2", []byte(`{"name":"Dieter","cards":[{"id":"card1","text":"bar"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 11, change.Seq)
+
+	// errors of the draft function change nothing
+	_, err = doc.Change("client1", func(draft *any) error {
+		*draft = map[string]any{}
+		return errors.New("abort")
+	})
+	assert.Equal(t, "abort", err.Error())
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"bar"}],"name":"Dieter"}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 6)
+
+	// the resulting change is not applicable
+	_, err = doc.Commit("client1", []byte(`{"cards":[{"id":"card1"},{"id":"card1"}]}`))
+	assert.NotNil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"bar"}],"name":"Dieter"}`, string(doc.JSON()))
+
+	change, err = doc.Commit("client1", []byte(`{"name":"Dieter","cards":[]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, change.Seq)
+}
@@ -0,0 +1,326 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrPathNotFound = errors.New("path not found")
+
+// Blame returns the change, that wrote the value at path last. Paths can use
+// identifiers like `/users/[u1]/name`. If the value was written as part of a
+// parent value, the change of the parent is returned.
+func (d *Document) Blame(path string) (Change, error) {
+	blame, err := d.BlameMap()
+	if err != nil {
+		return Change{}, err
+	}
+
+	target, err := resolveBlamePath(d.root, path, d.identifiers)
+	if err != nil {
+		return Change{}, ErrPathNotFound
+	}
+
+	current := target.path()
+	for {
+		if change, ok := blame[current]; ok {
+			return change, nil
+		}
+
+		if current == "" {
+			return Change{}, ErrPathNotFound
+		}
+		current = current[:strings.LastIndex(current, "/")]
+	}
+}
+
+// BlameMap returns the change, that wrote a path last, for all paths written by
+// the history. Identifiable array items use identifier paths like
+// `/users/[u1]/name`, so they are tracked across moves and removals.
+func (d *Document) BlameMap() (map[string]Change, error) {
+	replay := d.Clone()
+
+	if err := replay.rewindWhile(func(Change) bool { return true }); err != nil {
+		return nil, fmt.Errorf("blame error: %s", err.Error())
+	}
+
+	blame := map[string]Change{}
+
+	initial := replay.history[0]
+	for _, operation := range initial.Diff {
+		target, err := resolveBlamePath(replay.root, operation.Path, replay.identifiers)
+		if err != nil {
+			continue
+		}
+		blame[target.path()] = initial
+	}
+
+	for i := len(replay.stash) - 1; i >= 0; i-- {
+		change := replay.stash[i]
+
+		for _, operation := range change.Diff {
+			if err := blameOperation(blame, replay.root, change, operation, replay.identifiers); err != nil {
+				return nil, fmt.Errorf("blame error: changeID %s: %s", change.ChangeID, err.Error())
+			}
+
+			if _, err := replay.patch([]Operation{operation}); err != nil {
+				return nil, fmt.Errorf("blame error: changeID %s: %s", change.ChangeID, err.Error())
+			}
+		}
+	}
+
+	return blame, nil
+}
+
+// blameOperation updates the blame map by an operation before it is applied to root.
+func blameOperation(blame map[string]Change, root *node, change Change, operation Operation, identifiers [][]string) error {
+	switch operation.Op {
+	case "add":
+		target, err := resolveBlamePath(root, operation.Path, identifiers)
+		if err != nil {
+			return err
+		}
+		blameAdd(blame, target, operation.Value, identifiers, change, nil)
+	case "replace":
+		target, err := resolveBlamePath(root, operation.Path, identifiers)
+		if err != nil {
+			return err
+		}
+		// the value is replaced in place
+		target.insert = false
+		blameRemove(blame, target, false)
+		blameAdd(blame, target, operation.Value, identifiers, change, nil)
+	case "remove":
+		target, err := resolveBlamePath(root, operation.Path, identifiers)
+		if err != nil {
+			return err
+		}
+		blameRemove(blame, target, target.array)
+	case "copy":
+		from, err := resolveBlamePath(root, operation.From, identifiers)
+		if err != nil {
+			return err
+		}
+		target, err := resolveBlamePath(root, operation.Path, identifiers)
+		if err != nil {
+			return err
+		}
+		blameAdd(blame, target, rawMessage(string(from.value().JSON())), identifiers, change, nil)
+	case "move":
+		from, err := resolveBlamePath(root, operation.From, identifiers)
+		if err != nil {
+			return err
+		}
+		value := rawMessage(string(from.value().JSON()))
+		moved := blameRemove(blame, from, from.array)
+
+		// the target is resolved without the moved value
+		removed, _, err := patchTree(root, []Operation{{Op: "remove", Path: operation.From}}, identifiers)
+		if err != nil {
+			return err
+		}
+		target, err := resolveBlamePath(removed, operation.Path, identifiers)
+		if err != nil {
+			return err
+		}
+		blameAdd(blame, target, value, identifiers, change, moved)
+	}
+
+	return nil
+}
+
+// blameAdd blames the target and keeps the entries of a moved subtree.
+func blameAdd(blame map[string]Change, target blamePath, value *json.RawMessage, identifiers [][]string, change Change, moved map[string]Change) {
+	if target.array {
+		id := ""
+		if value != nil {
+			id = findID(*value, identifiers)
+		}
+
+		if id != "" {
+			target.key = ID(id)
+		} else {
+			target.key = Index(target.index)
+		}
+
+		if target.insert {
+			shiftBlameIndexes(blame, target.parent.String(), target.index, 1)
+		}
+	}
+
+	path := target.path()
+	for key := range blame {
+		if strings.HasPrefix(key, path+"/") {
+			delete(blame, key)
+		}
+	}
+
+	for relative, movedChange := range moved {
+		blame[path+relative] = movedChange
+	}
+	blame[path] = change
+}
+
+// blameRemove removes the entries of the target and its subtree. It returns the
+// removed entries relative to the target.
+func blameRemove(blame map[string]Change, target blamePath, shift bool) map[string]Change {
+	path := target.path()
+	removed := map[string]Change{}
+
+	for key, change := range blame {
+		if key == path || strings.HasPrefix(key, path+"/") {
+			if key != path {
+				removed[key[len(path):]] = change
+			}
+			delete(blame, key)
+		}
+	}
+
+	if shift {
+		shiftBlameIndexes(blame, target.parent.String(), target.index+1, -1)
+	}
+
+	return removed
+}
+
+// shiftBlameIndexes moves all index based entries of an array starting at index by delta.
+func shiftBlameIndexes(blame map[string]Change, array string, index, delta int) {
+	prefix := array + "/"
+	shifted := map[string]Change{}
+
+	for key, change := range blame {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		rest := key[len(prefix):]
+		part, tail, _ := strings.Cut(rest, "/")
+		position, err := strconv.Atoi(part)
+		if err != nil || position < index {
+			continue
+		}
+
+		if tail != "" {
+			tail = "/" + tail
+		}
+
+		delete(blame, key)
+		shifted[prefix+strconv.Itoa(position+delta)+tail] = change
+	}
+
+	for key, change := range shifted {
+		blame[key] = change
+	}
+}
+
+// blamePath is a resolved path. Identifiable array items are addressed by their identifiers.
+type blamePath struct {
+	parent Path
+	key    Segment
+	// array is true, if the parent is an array
+	array bool
+	// index is the position in the array
+	index int
+	// insert is true, if the path points behind the last element or to an identifier
+	insert bool
+	// container is the parent node
+	container *node
+}
+
+func (p blamePath) path() string {
+	return p.parent.Append(p.key).String()
+}
+
+// value returns the node at the resolved path.
+func (p blamePath) value() *node {
+	if p.array {
+		_, items := p.container.children()
+		if p.index < len(items) {
+			return items[p.index]
+		}
+		return nullNode
+	}
+
+	if child, ok := p.container.field(p.key.text()); ok {
+		return child
+	}
+	return nullNode
+}
+
+// resolveBlamePath resolves a path like `/cards/0/name` or `/cards/[id]/name` to
+// a path with identifiers for all identifiable array items.
+func resolveBlamePath(root *node, path string, identifiers [][]string) (blamePath, error) {
+	p, err := ParsePath(path)
+	if err != nil || len(p) == 0 {
+		return blamePath{}, fmt.Errorf("invalid path `%s`", path)
+	}
+
+	resolved := blamePath{parent: Path{}}
+	current := root
+
+	for i, segment := range p {
+		last := i == len(p)-1
+
+		key := segment
+		index := 0
+		insert := false
+		var child *node
+
+		switch current.kind {
+		case kindArray:
+			_, items := current.children()
+
+			switch segment.Kind {
+			case SegmentEnd:
+				index = len(items)
+				insert = true
+			case SegmentID:
+				position, ok := current.indexOf(segment.ID, identifiers)
+				if !ok {
+					return blamePath{}, errors.New("id `" + segment.ID + "` not found")
+				}
+				index = position
+				insert = true
+			case SegmentIndex:
+				index = min(segment.Index, len(items))
+				insert = true
+			default:
+				return blamePath{}, fmt.Errorf("invalid index `%s`", segment)
+			}
+
+			if index < len(items) {
+				child = items[index]
+				if id := child.itemID(identifiers); id != "" {
+					key = ID(id)
+				} else {
+					key = Index(index)
+				}
+			}
+		case kindObject:
+			child, _ = current.field(segment.text())
+		default:
+			return blamePath{}, fmt.Errorf("path `%s` not found", path)
+		}
+
+		if last {
+			resolved.key = key
+			resolved.array = current.kind == kindArray
+			resolved.index = index
+			resolved.insert = insert
+			resolved.container = current
+			break
+		}
+
+		if child == nil {
+			return blamePath{}, fmt.Errorf("path `%s` not found", path)
+		}
+
+		resolved.parent = resolved.parent.Append(key)
+		current = child
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,144 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlame(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp","users":[{"id":"u1","name":"Anna"},{"id":"u2","name":"Ben"}],"tags":["a","b"]}`))
+	assert.Nil(t, err)
+
+	for _, change := range []Change{
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/users/[u1]/name", Value: rawMessage(`"Anne"`), Prev: rawMessage(`"Anna"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client1",
+			ChangeID:        "change1",
+		},
+		{
+			Diff:            []Operation{{Op: "move", From: "/users/[u1]", Path: "/users/1"}},
+			TimestampMillis: 20,
+			ClientID:        "client2",
+			ChangeID:        "change2",
+		},
+		{
+			Diff:            []Operation{{Op: "add", Path: "/users/0", Value: rawMessage(`{"id":"u3","name":"Carl"}`)}},
+			TimestampMillis: 30,
+			ClientID:        "client3",
+			ChangeID:        "change3",
+		},
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/tags/1", Value: rawMessage(`"c"`), Prev: rawMessage(`"b"`)}},
+			TimestampMillis: 40,
+			ClientID:        "client1",
+			ChangeID:        "change4",
+		},
+		{
+			Diff:            []Operation{{Op: "add", Path: "/tags/0", Value: rawMessage(`"z"`)}},
+			TimestampMillis: 50,
+			ClientID:        "client2",
+			ChangeID:        "change5",
+		},
+	} {
+		assert.Nil(t, doc.ApplyChange(change))
+	}
+
+	testCases := []struct {
+		path     string
+		changeID string
+	}{
+		{path: "/name", changeID: "0"},
+		{path: "/users/[u1]/name", changeID: "change1"},
+		{path: "/users/2/name", changeID: "change1"},
+		{path: "/users/[u1]", changeID: "change2"},
+		{path: "/users/[u2]/name", changeID: "0"},
+		{path: "/users/[u3]/name", changeID: "change3"},
+		{path: "/users/0", changeID: "change3"},
+		{path: "/tags/0", changeID: "change5"},
+		{path: "/tags/1", changeID: "0"},
+		{path: "/tags/2", changeID: "change4"},
+	}
+
+	for _, testCase := range testCases {
+		change, err := doc.Blame(testCase.path)
+		assert.Nil(t, err, testCase.path)
+		assert.Equal(t, testCase.changeID, change.ChangeID, testCase.path)
+	}
+
+	_, err = doc.Blame("/users/[u4]/name")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestBlameMap(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"cards":[{"id":"card1","text":"foo"},{"id":"card2","text":"bar"}]}`))
+	assert.Nil(t, err)
+
+	for _, change := range []Change{
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/cards/[card2]/text", Value: rawMessage(`"baz"`), Prev: rawMessage(`"bar"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client1",
+			ChangeID:        "change1",
+		},
+		{
+			Diff:            []Operation{{Op: "remove", Path: "/cards/[card1]", Prev: rawMessage(`{"id":"card1","text":"foo"}`)}},
+			TimestampMillis: 20,
+			ClientID:        "client2",
+			ChangeID:        "change2",
+		},
+		{
+			Diff:            []Operation{{Op: "add", Path: "/title", Value: rawMessage(`"Board"`)}},
+			TimestampMillis: 30,
+			ClientID:        "client2",
+			ChangeID:        "change3",
+		},
+	} {
+		assert.Nil(t, doc.ApplyChange(change))
+	}
+
+	blame, err := doc.BlameMap()
+	assert.Nil(t, err)
+
+	changeIDs := map[string]string{}
+	for path, change := range blame {
+		changeIDs[path] = change.ChangeID
+	}
+
+	assert.Equal(t, map[string]string{
+		"/cards":              "0",
+		"/cards/[card2]/text": "change1",
+		"/title":              "change3",
+	}, changeIDs)
+
+	// the document is unchanged
+	assert.Equal(t, `{"cards":[{"id":"card2","text":"baz"}],"title":"Board"}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 4)
+}
+
+func TestBlameRootArray(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`[{"id":"a","n":1},{"id":"b","n":2}]`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/[b]/n", Value: rawMessage(`3`), Prev: rawMessage(`2`)}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+
+	change, err := doc.Blame("/0/n")
+	assert.Nil(t, err)
+	assert.Equal(t, "0", change.ChangeID)
+
+	change, err = doc.Blame("/1/n")
+	assert.Nil(t, err)
+	assert.Equal(t, "change1", change.ChangeID)
+}
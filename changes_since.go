@@ -0,0 +1,116 @@
+package pigeongo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SnapshotRequiredError is returned, if the history does not cover the
+// requested changes anymore. The client needs a full snapshot of the document.
+type SnapshotRequiredError struct {
+	// ChangeID or TimestampMillis of the request
+	ChangeID        string
+	TimestampMillis int64
+	// HistoryStart is the timestamp of the initial change
+	HistoryStart int64
+	// LateChangeID is a change before TimestampMillis, that arrived after newer
+	// changes. A client with the timestamp may have missed it.
+	LateChangeID string
+}
+
+func (e *SnapshotRequiredError) Error() string {
+	if e.ChangeID != "" {
+		return fmt.Sprintf("snapshot required: changeID %s is not in the history", e.ChangeID)
+	}
+
+	if e.LateChangeID != "" {
+		return fmt.Sprintf("snapshot required: changeID %s arrived late before timestamp %d", e.LateChangeID, e.TimestampMillis)
+	}
+
+	return fmt.Sprintf("snapshot required: timestamp %d is before the history start %d", e.TimestampMillis, e.HistoryStart)
+}
+
+// ChangesSince returns all changes, that arrived after the change with changeID,
+// in the order of the history. Late changes, that are ordered before the change,
+// are returned, too. If the change is unknown or folded into the initial diff, a
+// *SnapshotRequiredError is returned.
+func (d *Document) ChangesSince(changeID string) ([]Change, error) {
+	cursor := -1
+	for i := len(d.history) - 1; i >= 0; i-- {
+		if d.history[i].ChangeID == changeID {
+			cursor = i
+			break
+		}
+	}
+
+	if cursor < 0 {
+		return nil, &SnapshotRequiredError{
+			ChangeID:     changeID,
+			HistoryStart: d.history[0].TimestampMillis,
+		}
+	}
+
+	changes := []Change{}
+	for i := 1; i < len(d.history); i++ {
+		if d.arrivedAfter(i, cursor) {
+			changes = append(changes, d.history[i])
+		}
+	}
+
+	return changes, nil
+}
+
+// ChangesSinceTimestamp returns all changes newer than timestampMillis in the
+// order of the history. If older changes are folded into the initial diff or a
+// late change older than timestampMillis arrived after newer changes, a
+// *SnapshotRequiredError is returned.
+func (d *Document) ChangesSinceTimestamp(timestampMillis int64) ([]Change, error) {
+	if timestampMillis < d.history[0].TimestampMillis {
+		return nil, &SnapshotRequiredError{
+			TimestampMillis: timestampMillis,
+			HistoryStart:    d.history[0].TimestampMillis,
+		}
+	}
+
+	// the older changes in the order of their arrival
+	older := []int{}
+	for i := 1; i < len(d.history) && d.history[i].TimestampMillis <= timestampMillis; i++ {
+		older = append(older, i)
+	}
+	sort.SliceStable(older, func(a, b int) bool {
+		return d.arrivedAfter(older[b], older[a])
+	})
+
+	for i, index := range older {
+		if i > 0 && d.history[index].TimestampMillis <= d.history[older[i-1]].TimestampMillis {
+			return nil, &SnapshotRequiredError{
+				TimestampMillis: timestampMillis,
+				HistoryStart:    d.history[0].TimestampMillis,
+				LateChangeID:    d.history[index].ChangeID,
+			}
+		}
+	}
+
+	changes := []Change{}
+	for _, change := range d.history[1:] {
+		if change.TimestampMillis > timestampMillis {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+// arrivedAfter returns true, if the change at index i of the history arrived
+// after the change at index j. The initial diff and loaded changes arrived first
+// in the order of the history.
+func (d *Document) arrivedAfter(i, j int) bool {
+	a, b := d.received[d.history[i].ChangeID], d.received[d.history[j].ChangeID]
+	if j == 0 {
+		b = 0
+	}
+	if a != b {
+		return a > b
+	}
+	return i > j
+}
@@ -0,0 +1,129 @@
+package pigeongo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangesSince(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"count":0}`))
+	assert.Nil(t, err)
+
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage(fmt.Sprintf("%d", i))}},
+			TimestampMillis: int64(i * 10),
+			ClientID:        "client1",
+			ChangeID:        fmt.Sprintf("change%d", i),
+		}))
+	}
+
+	changes, err := doc.ChangesSince("change2")
+	assert.Nil(t, err)
+	assert.Equal(t, doc.History()[3:], changes)
+
+	changes, err = doc.ChangesSince("0")
+	assert.Nil(t, err)
+	assert.Equal(t, doc.History()[1:], changes)
+
+	changes, err = doc.ChangesSince("change4")
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = doc.ChangesSinceTimestamp(25)
+	assert.Nil(t, err)
+	assert.Equal(t, doc.History()[3:], changes)
+
+	changes, err = doc.ChangesSinceTimestamp(0)
+	assert.Nil(t, err)
+	assert.Equal(t, doc.History()[1:], changes)
+
+	_, err = doc.ChangesSince("unknown")
+	var snapshotErr *SnapshotRequiredError
+	assert.True(t, errors.As(err, &snapshotErr))
+	assert.Equal(t, "unknown", snapshotErr.ChangeID)
+
+	// folded changes require a snapshot
+	assert.Nil(t, doc.ReduceHistory(25))
+
+	_, err = doc.ChangesSince("change1")
+	assert.EqualError(t, err, "snapshot required: changeID change1 is not in the history")
+
+	_, err = doc.ChangesSinceTimestamp(15)
+	assert.EqualError(t, err, "snapshot required: timestamp 15 is before the history start 20")
+	assert.True(t, errors.As(err, &snapshotErr))
+	assert.Equal(t, int64(20), snapshotErr.HistoryStart)
+
+	changes, err = doc.ChangesSince("change2")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"change3", "change4"}, []string{changes[0].ChangeID, changes[1].ChangeID})
+
+	changes, err = doc.ChangesSinceTimestamp(20)
+	assert.Nil(t, err)
+	assert.Len(t, changes, 2)
+}
+
+func TestChangesSinceLateChange(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"count":0}`))
+	assert.Nil(t, err)
+
+	change := func(changeID string, timestampMillis int64) Change {
+		return Change{
+			Diff:            []Operation{{Op: "add", Path: "/" + changeID, Value: rawMessage("true")}},
+			TimestampMillis: timestampMillis,
+			ClientID:        "client1",
+			ChangeID:        changeID,
+		}
+	}
+
+	assert.Nil(t, doc.ApplyChange(change("a", 10)))
+	assert.Nil(t, doc.ApplyChange(change("x", 30)))
+
+	// the client knows x, the late change is ordered before x
+	assert.Nil(t, doc.ApplyChange(change("late", 20)))
+	assert.Nil(t, doc.ApplyChange(change("b", 40)))
+	assert.Equal(t, []string{"0", "a", "late", "x", "b"}, idsOf(doc.History()))
+
+	changes, err := doc.ChangesSince("x")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"late", "b"}, idsOf(changes))
+
+	changes, err = doc.ChangesSince("late")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b"}, idsOf(changes))
+
+	changes, err = doc.ChangesSince("a")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"late", "x", "b"}, idsOf(changes))
+
+	// the timestamp doesn't tell, if the client has the late change
+	_, err = doc.ChangesSinceTimestamp(30)
+	var snapshotErr *SnapshotRequiredError
+	assert.True(t, errors.As(err, &snapshotErr))
+	assert.Equal(t, "late", snapshotErr.LateChangeID)
+	assert.EqualError(t, err, "snapshot required: changeID late arrived late before timestamp 30")
+
+	changes, err = doc.ChangesSinceTimestamp(10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"late", "x", "b"}, idsOf(changes))
+
+	// clones keep the arrival order
+	changes, err = doc.Clone().ChangesSince("x")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"late", "b"}, idsOf(changes))
+}
+
+func idsOf(changes []Change) []string {
+	ids := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = change.ChangeID
+	}
+	return ids
+}
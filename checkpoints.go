@@ -0,0 +1,144 @@
+package pigeongo
+
+// checkpoint is a snapshot of the document after the first length changes of the history.
+type checkpoint struct {
+	length int
+	raw    []byte
+	root   *node
+}
+
+// WithCheckpoints keeps a snapshot of the document after every number of changes. A late
+// change restores the nearest snapshot before its timestamp and applies the
+// newer changes of the history again, instead of reversing all newer changes
+// one by one. Snapshots share unchanged parts of the document and are cheap.
+//
+// Checkpoints have two limits:
+//   - They are not used while observers are registered, because observers get
+//     the reversed operations of every rewound change.
+//   - Objects restored from a snapshot keep their key order, reversed changes
+//     sort the keys of the touched objects. The JSON of a rewound document, like
+//     the result of At or a folded initial diff, can differ in key order from a
+//     replica without checkpoints. Values and Checksum are the same.
+func WithCheckpoints(changes int) DocumentOption {
+	return func(d *Document) {
+		d.checkpointChanges = changes
+	}
+}
+
+// WithCheckpointBytes keeps a snapshot of the document, after the operations of
+// the changes since the last snapshot exceed the size in bytes. It can be
+// combined with WithCheckpoints.
+func WithCheckpointBytes(bytes int) DocumentOption {
+	return func(d *Document) {
+		d.checkpointBytes = bytes
+	}
+}
+
+// addCheckpoint takes a snapshot after the last change of the history, if a limit is reached.
+func (d *Document) addCheckpoint() {
+	if d.checkpointChanges <= 0 && d.checkpointBytes <= 0 {
+		return
+	}
+
+	last := 1
+	if len(d.checkpoints) > 0 {
+		last = d.checkpoints[len(d.checkpoints)-1].length
+	}
+
+	changes := len(d.history) - last
+	if changes <= 0 {
+		return
+	}
+
+	reached := d.checkpointChanges > 0 && changes >= d.checkpointChanges
+	if !reached && d.checkpointBytes > 0 {
+		size := 0
+		for _, change := range d.history[last:] {
+			size += changeSize(change)
+		}
+		reached = size >= d.checkpointBytes
+	}
+
+	if reached {
+		d.checkpoints = append(d.checkpoints, checkpoint{
+			length: len(d.history),
+			raw:    d.raw,
+			root:   d.root,
+		})
+	}
+}
+
+// dropCheckpoints removes all snapshots, that contain more than length changes.
+func (d *Document) dropCheckpoints(length int) {
+	i := len(d.checkpoints)
+	for i > 0 && d.checkpoints[i-1].length > length {
+		i--
+	}
+	d.checkpoints = d.checkpoints[:i]
+}
+
+// foldCheckpoints moves the snapshots after the history is folded into a new
+// initial diff of the first folded changes.
+func (d *Document) foldCheckpoints(folded int) {
+	checkpoints := []checkpoint{}
+	for _, c := range d.checkpoints {
+		if c.length > folded {
+			c.length = c.length - folded + 1
+			checkpoints = append(checkpoints, c)
+		}
+	}
+	d.checkpoints = checkpoints
+}
+
+// rewindCheckpoint rewinds the newest changes of the history until length
+// changes are left by restoring the nearest snapshot and applying the changes
+// between the snapshot and length again. It returns false, if no snapshot is
+// cheaper than reversing the changes.
+func (d *Document) rewindCheckpoint(length int) (bool, error) {
+	// observers get the reversed operations of every change
+	if d.recordEvents {
+		return false, nil
+	}
+
+	i := len(d.checkpoints)
+	for i > 0 && d.checkpoints[i-1].length > length {
+		i--
+	}
+	if i == 0 || length-d.checkpoints[i-1].length >= len(d.history)-length {
+		return false, nil
+	}
+	c := d.checkpoints[i-1]
+
+	// move the newer changes to the stash, newest first
+	for j := len(d.history) - 1; j >= length; j-- {
+		delete(d.changeIDs, d.history[j].ChangeID)
+		d.stash = append(d.stash, d.history[j])
+	}
+
+	replay := d.history[c.length:length]
+	d.history = d.history[:c.length]
+	d.raw, d.root = c.raw, c.root
+	d.dropCheckpoints(c.length)
+
+	for _, change := range replay {
+		if _, _, err := d.applyOperations(change.ChangeID, PhaseFastForward, change.Diff, false); err != nil {
+			return false, err
+		}
+		d.history = append(d.history, change)
+		d.addCheckpoint()
+	}
+
+	return true, nil
+}
+
+// changeSize returns the size of the paths and values of a change.
+func changeSize(change Change) int {
+	size := 0
+	for _, operation := range change.Diff {
+		size += len(operation.Path) + len(operation.From)
+		if operation.Value != nil {
+			size += len(*operation.Value)
+		}
+	}
+	return size
+}
@@ -0,0 +1,199 @@
+package pigeongo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkpointChanges() []Change {
+	changes := []Change{}
+	for i := 1; i <= 20; i++ {
+		changes = append(changes, Change{
+			Diff: []Operation{
+				{
+					Op:    "add",
+					Path:  fmt.Sprintf("/cards/%d", i-1),
+					Value: rawMessage(fmt.Sprintf(`{"id":"card%d","count":%d}`, i, i)),
+				},
+				{
+					Op:    "replace",
+					Path:  "/count",
+					Value: rawMessage(fmt.Sprintf("%d", i)),
+				},
+			},
+			TimestampMillis: int64(i * 10),
+			ClientID:        fmt.Sprintf("client%d", i%3),
+			ChangeID:        fmt.Sprintf("change%d", i),
+		})
+	}
+
+	// late changes
+	for _, i := range []int{17, 3, 12, 1} {
+		changes = append(changes, Change{
+			Diff: []Operation{
+				{
+					Op:    "replace",
+					Path:  fmt.Sprintf("/cards/[card%d]/count", i),
+					Value: rawMessage("0"),
+				},
+			},
+			TimestampMillis: int64(i*10 + 5),
+			ClientID:        "late",
+			ChangeID:        fmt.Sprintf("late%d", i),
+		})
+	}
+
+	return changes
+}
+
+func TestCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		opts []DocumentOption
+	}{
+		{name: "changes", opts: []DocumentOption{WithCheckpoints(4)}},
+		{name: "bytes", opts: []DocumentOption{WithCheckpointBytes(100)}},
+		{name: "max history", opts: []DocumentOption{WithCheckpoints(3), WithMaxHistory(16)}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			raw := []byte(`{"cards":[],"count":0}`)
+
+			expected, err := NewDocument(raw, testCase.opts[1:]...)
+			assert.Nil(t, err)
+
+			doc, err := NewDocument(raw, testCase.opts...)
+			assert.Nil(t, err)
+
+			for _, change := range checkpointChanges() {
+				assert.Nil(t, expected.ApplyChange(change))
+				assert.Nil(t, doc.ApplyChange(change))
+
+				assert.Equal(t, string(expected.JSON()), string(doc.JSON()))
+				assert.Equal(t, expected.History(), doc.History())
+			}
+			assert.NotEmpty(t, doc.checkpoints)
+
+			// snapshots are taken at the current history
+			for _, c := range doc.checkpoints {
+				at := doc.Clone()
+				at.checkpoints = nil
+				rewound := len(at.history) - c.length
+				assert.Nil(t, at.rewindWhile(func(Change) bool {
+					rewound--
+					return rewound >= 0
+				}))
+				assert.JSONEq(t, string(at.JSON()), string(c.root.JSON()))
+			}
+
+			// time travel uses the snapshots
+			at, err := doc.At(105)
+			assert.Nil(t, err)
+			expectedAt, err := expected.At(105)
+			assert.Nil(t, err)
+			assert.JSONEq(t, string(expectedAt.JSON()), string(at.JSON()))
+		})
+	}
+}
+
+func TestCheckpointsRestore(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"count":0}`), WithCheckpoints(2))
+	assert.Nil(t, err)
+
+	for i := 1; i <= 6; i++ {
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage(fmt.Sprintf("%d", i))}},
+			TimestampMillis: int64(i * 10),
+			ClientID:        "client1",
+			ChangeID:        fmt.Sprintf("change%d", i),
+		}))
+	}
+
+	assert.Len(t, doc.checkpoints, 3)
+	assert.Equal(t, []int{3, 5, 7}, []int{doc.checkpoints[0].length, doc.checkpoints[1].length, doc.checkpoints[2].length})
+
+	// the rewind restores the snapshot after change4 and replays change5
+	workingCopy := doc.Clone()
+	assert.Nil(t, workingCopy.rewindChanges(55, "client2"))
+	assert.Equal(t, `{"count":5}`, string(workingCopy.JSON()))
+	assert.Len(t, workingCopy.History(), 6)
+	assert.Len(t, workingCopy.stash, 1)
+	assert.Equal(t, []int{3, 5}, []int{workingCopy.checkpoints[0].length, workingCopy.checkpoints[1].length})
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "add", Path: "/late", Value: rawMessage("true")}},
+		TimestampMillis: 15,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}))
+	assert.Equal(t, `{"count":6,"late":true}`, string(doc.JSON()))
+	assert.Equal(t, []int{3, 5, 7}, []int{doc.checkpoints[0].length, doc.checkpoints[1].length, doc.checkpoints[2].length})
+
+	// observers get all rewound changes
+	events := []Event{}
+	doc.Observe(func(event Event) {
+		events = append(events, event)
+	})
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "add", Path: "/late2", Value: rawMessage("true")}},
+		TimestampMillis: 25,
+		ClientID:        "client2",
+		ChangeID:        "late2",
+	}))
+	assert.Len(t, events, 9)
+
+	// the checkpoints aren't used, every change is reversed
+	rewound := 0
+	for _, event := range events {
+		if event.Type == EventRewound {
+			assert.NotEmpty(t, event.Operations)
+			rewound++
+		}
+	}
+	assert.Equal(t, 4, rewound)
+}
+
+func TestCheckpointsKeyOrder(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"obj":{"b":1,"a":2},"count":0}`)
+	expected, err := NewDocument(raw)
+	assert.Nil(t, err)
+	doc, err := NewDocument(raw, WithCheckpoints(2))
+	assert.Nil(t, err)
+
+	for i := 1; i <= 6; i++ {
+		path := "/count"
+		if i == 4 {
+			path = "/obj/b"
+		}
+		change := Change{
+			Diff:            []Operation{{Op: "replace", Path: path, Value: rawMessage(fmt.Sprintf("%d", i))}},
+			TimestampMillis: int64(i * 10),
+			ClientID:        "client1",
+			ChangeID:        fmt.Sprintf("change%d", i),
+		}
+		assert.Nil(t, expected.ApplyChange(change))
+		assert.Nil(t, doc.ApplyChange(change))
+	}
+	assert.Equal(t, string(expected.JSON()), string(doc.JSON()))
+
+	// the snapshot keeps the key order of the untouched object, the reversed
+	// change sorts it
+	expectedAt, err := expected.At(30)
+	assert.Nil(t, err)
+	at, err := doc.At(30)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"count":3,"obj":{"a":2,"b":1}}`, string(expectedAt.JSON()))
+	assert.Equal(t, `{"count":3,"obj":{"b":1,"a":2}}`, string(at.JSON()))
+	assert.Equal(t, expectedAt.Checksum(), at.Checksum())
+}
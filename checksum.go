@@ -0,0 +1,199 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PhaseChecksum is the phase of a warning for a diverged document.
+const PhaseChecksum = "checksum"
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// WithChecksums stamps authored changes with the checksum of the document after
+// the change. If a received change with a checksum is the newest change of the
+// history, the checksum is verified and a mismatch is reported by Warnings with
+// ErrChecksumMismatch. The change is applied anyway, the replicas diverged before.
+func WithChecksums() DocumentOption {
+	return func(d *Document) {
+		d.checksums = true
+	}
+}
+
+// Checksum returns the checksum of the document like PigeonJS `Pigeon.crc`.
+// Replicas with the same data have the same checksum.
+func (d *Document) Checksum() int64 {
+	return checksum(d.root)
+}
+
+// Checksum returns the checksum of raw like PigeonJS `Pigeon.crc`.
+func Checksum(raw []byte) (int64, error) {
+	if !json.Valid(raw) {
+		return 0, errors.New("checksum error: invalid json")
+	}
+
+	return checksum(newNode(raw)), nil
+}
+
+// checksum hashes the stable serialization of PigeonJS with `(crc << 5) - crc + c`
+// over the UTF-16 code units. Like in JavaScript the shift is done on 32 bit
+// integers and the sum on doubles.
+func checksum(n *node) int64 {
+	crc := 0.0
+	for _, r := range string(stableJSON(nil, n)) {
+		units := []rune{r}
+		if r >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(r)
+			units = []rune{r1, r2}
+		}
+
+		for _, unit := range units {
+			crc = float64(int32(int64(crc))<<5) - crc + float64(unit)
+		}
+	}
+
+	return int64(math.Abs(crc))
+}
+
+// stableJSON serializes like `JSON.stringify` with sorted keys.
+func stableJSON(dst []byte, n *node) []byte {
+	switch n.kind {
+	case kindObject:
+		fields, _ := n.children()
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sortUTF16(keys)
+
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSString(dst, key)
+			dst = append(dst, ':')
+			dst = stableJSON(dst, fields[key])
+		}
+		return append(dst, '}')
+	case kindArray:
+		_, items := n.children()
+
+		dst = append(dst, '[')
+		for i, item := range items {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = stableJSON(dst, item)
+		}
+		return append(dst, ']')
+	case kindString:
+		var s string
+		_ = json.Unmarshal(n.raw, &s)
+		return appendJSString(dst, s)
+	case kindNumber:
+		v, _ := strconv.ParseFloat(string(n.raw), 64)
+		return append(dst, formatJSNumber(v)...)
+	default:
+		// null and booleans are serialized like the source
+		return append(dst, n.raw...)
+	}
+}
+
+// sortUTF16 sorts like `Array.prototype.sort` by UTF-16 code units.
+func sortUTF16(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := utf16.Encode([]rune(keys[i])), utf16.Encode([]rune(keys[j]))
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
+
+// appendJSString appends a string like `JSON.stringify`.
+func appendJSString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			dst = append(dst, '\\', '"')
+		case '\\':
+			dst = append(dst, '\\', '\\')
+		case '\b':
+			dst = append(dst, '\\', 'b')
+		case '\f':
+			dst = append(dst, '\\', 'f')
+		case '\n':
+			dst = append(dst, '\\', 'n')
+		case '\r':
+			dst = append(dst, '\\', 'r')
+		case '\t':
+			dst = append(dst, '\\', 't')
+		default:
+			if c < 0x20 {
+				dst = append(dst, fmt.Sprintf(`\u%04x`, c)...)
+			} else {
+				dst = append(dst, c)
+			}
+		}
+	}
+	return append(dst, '"')
+}
+
+// formatJSNumber formats a number like `Number.prototype.toString`.
+func formatJSNumber(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+
+	abs := math.Abs(v)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	// 1e-07 becomes 1e-7
+	s := strconv.FormatFloat(v, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign, digits := exponent[:1], strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + sign + digits
+}
+
+// verifyChecksums reports received changes, that are the newest change of the
+// history and have a different checksum.
+func (d *Document) verifyChecksums(changes []Change) {
+	if !d.checksums || len(d.history) < 2 {
+		return
+	}
+
+	last := d.history[len(d.history)-1]
+	for _, change := range changes {
+		if change.ChangeID != last.ChangeID || change.Checksum == nil {
+			continue
+		}
+
+		if actual := d.Checksum(); actual != *change.Checksum {
+			d.warn(change.ChangeID, PhaseChecksum, -1, fmt.Errorf("%w: expected %d, got %d", ErrChecksumMismatch, *change.Checksum, actual))
+		}
+	}
+}
+
+// stampChecksum sets the checksum of an authored change, that is the newest change of the history.
+func (d *Document) stampChecksum(change *Change) {
+	if !d.checksums || d.history[len(d.history)-1].ChangeID != change.ChangeID {
+		return
+	}
+
+	crc := d.Checksum()
+	change.Checksum = &crc
+	d.history[len(d.history)-1].Checksum = &crc
+}
@@ -0,0 +1,78 @@
+package pigeongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+
+	// expected values are generated with PigeonJS `Pigeon.crc`
+	testCases := []struct {
+		doc  string
+		want int64
+	}{
+		{doc: `{}`, want: 3938},
+		{doc: `{"name":"Alice","age":30}`, want: 45792257},
+		{doc: `{ "b": [1, 2.5, {"z": null, "a": true}], "a": "x\ny\"<&>" }`, want: 9050480894},
+		{doc: `{"big":1e21,"small":1e-7,"neg":-0.000001,"exp":1.5e300,"int":12345678901234567890}`, want: 21802062985},
+		{doc: `{"ü":"日本語","😀":"emoji 😀","\ue000":1,"a\u0001":"ctrl\u001f"}`, want: 932230616},
+		{doc: `{"cards":[{"id":"c1","text":"lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"}]}`, want: 4033580347},
+		{doc: `[1,"2",[3]]`, want: 2003319256},
+	}
+
+	for _, testCase := range testCases {
+		got, err := Checksum([]byte(testCase.doc))
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.want, got, testCase.doc)
+
+		doc, err := NewDocument([]byte(testCase.doc))
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.want, doc.Checksum(), testCase.doc)
+	}
+
+	_, err := Checksum([]byte(`{`))
+	assert.NotNil(t, err)
+}
+
+func TestChecksums(t *testing.T) {
+	t.Parallel()
+
+	author, err := NewDocument([]byte(`{"name":"Alice"}`), WithChecksums())
+	assert.Nil(t, err)
+
+	change, err := author.Commit("client1", []byte(`{"name":"Bob","age":30}`))
+	assert.Nil(t, err)
+	assert.NotNil(t, change.Checksum)
+	assert.Equal(t, author.Checksum(), *change.Checksum)
+	assert.Equal(t, change.Checksum, author.History()[1].Checksum)
+
+	// same state
+	replica, err := NewDocument([]byte(`{"name":"Alice"}`), WithChecksums())
+	assert.Nil(t, err)
+	assert.Nil(t, replica.ApplyChange(change))
+	assert.Empty(t, replica.Warnings())
+
+	// diverged state
+	diverged, err := NewDocument([]byte(`{"name":"Alice","city":"Berlin"}`), WithChecksums())
+	assert.Nil(t, err)
+	assert.Nil(t, diverged.ApplyChange(change))
+	assert.Equal(t, `{"age":30,"city":"Berlin","name":"Bob"}`, string(diverged.JSON()))
+	assert.Len(t, diverged.Warnings(), 1)
+	assert.Equal(t, PhaseChecksum, diverged.Warnings()[0].Phase)
+	assert.True(t, errors.Is(diverged.Warnings()[0].Err, ErrChecksumMismatch))
+
+	// older changes are not verified
+	late := Change{
+		Diff:            []Operation{{Op: "add", Path: "/late", Value: rawMessage("true")}},
+		TimestampMillis: 1,
+		ClientID:        "client2",
+		ChangeID:        "late",
+		Checksum:        change.Checksum,
+	}
+	assert.Nil(t, replica.ApplyChange(late))
+	assert.Empty(t, replica.Warnings())
+}
@@ -0,0 +1,82 @@
+package pigeongo
+
+import "sort"
+
+// Pending returns the changes, that wait for missing dependencies. They are
+// applied automatically, once all changes of their Deps are known.
+func (d *Document) Pending() []Change {
+	return append([]Change{}, d.pending...)
+}
+
+// MissingDeps returns the sorted changeIDs, that pending changes depend on and
+// that are not known yet, like PigeonJS `getMissingDeps`.
+func (d *Document) MissingDeps() []string {
+	pending := map[string]bool{}
+	for _, change := range d.pending {
+		pending[change.ChangeID] = true
+	}
+
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, change := range d.pending {
+		for _, dep := range change.Deps {
+			if _, ok := d.changeIDs[dep]; ok || pending[dep] || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			missing = append(missing, dep)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing
+}
+
+// readyChanges splits the pending and the new changes into the changes, whose
+// dependencies are known, and the changes, that still wait. Known and duplicated
+// changeIDs are skipped.
+func (d *Document) readyChanges(changes []Change) ([]Change, []Change) {
+	candidates := make([]Change, 0, len(d.pending)+len(changes))
+	seen := map[string]bool{}
+	for _, change := range append(append([]Change{}, d.pending...), changes...) {
+		if _, ok := d.changeIDs[change.ChangeID]; ok || seen[change.ChangeID] {
+			continue
+		}
+		seen[change.ChangeID] = true
+		candidates = append(candidates, change)
+	}
+
+	ready := map[string]bool{}
+	for progress := true; progress; {
+		progress = false
+		for _, change := range candidates {
+			if ready[change.ChangeID] || !d.depsKnown(change, ready) {
+				continue
+			}
+			ready[change.ChangeID] = true
+			progress = true
+		}
+	}
+
+	batch := make([]Change, 0, len(ready))
+	pending := []Change{}
+	for _, change := range candidates {
+		if ready[change.ChangeID] {
+			batch = append(batch, change)
+		} else {
+			pending = append(pending, change)
+		}
+	}
+
+	return batch, pending
+}
+
+// depsKnown returns true, if all dependencies are applied or ready.
+func (d *Document) depsKnown(change Change, ready map[string]bool) bool {
+	for _, dep := range change.Deps {
+		if _, ok := d.changeIDs[dep]; !ok && !ready[dep] {
+			return false
+		}
+	}
+	return true
+}
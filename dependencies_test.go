@@ -0,0 +1,99 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencies(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"items":[]}`))
+	assert.Nil(t, err)
+
+	add := Change{
+		Diff:            []Operation{{Op: "add", Path: "/items/0", Value: rawMessage(`{"id":"x","name":"a"}`)}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "add",
+	}
+	rename := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/items/[x]/name", Value: rawMessage(`"b"`)}},
+		TimestampMillis: 20,
+		ClientID:        "client2",
+		ChangeID:        "rename",
+		Deps:            []string{"add"},
+	}
+	remove := Change{
+		Diff:            []Operation{{Op: "remove", Path: "/items/[x]"}},
+		TimestampMillis: 30,
+		ClientID:        "client1",
+		ChangeID:        "remove",
+		Deps:            []string{"rename"},
+	}
+
+	// the rename arrives before the add and waits
+	assert.Nil(t, doc.ApplyChange(remove))
+	assert.Nil(t, doc.ApplyChange(rename))
+	assert.Equal(t, `{"items":[]}`, string(doc.JSON()))
+	assert.Equal(t, []string{"remove", "rename"}, []string{doc.Pending()[0].ChangeID, doc.Pending()[1].ChangeID})
+	assert.Equal(t, []string{"add"}, doc.MissingDeps())
+
+	// pending changes are not applied twice
+	assert.Nil(t, doc.ApplyChange(rename))
+	assert.Len(t, doc.Pending(), 2)
+
+	// the add releases all pending changes
+	events := []Event{}
+	doc.Observe(func(event Event) {
+		events = append(events, event)
+	})
+	assert.Nil(t, doc.ApplyChange(add))
+	assert.Equal(t, `{"items":[]}`, string(doc.JSON()))
+	assert.Empty(t, doc.Pending())
+	assert.Empty(t, doc.MissingDeps())
+	assert.Len(t, doc.History(), 4)
+	assert.Len(t, events, 3)
+	assert.Equal(t, `{"id":"x","name":"b"}`, string(*doc.History()[3].Diff[0].Prev))
+}
+
+func TestDependenciesFailed(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"items":[]}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/items/[y]/name", Value: rawMessage(`"b"`)}},
+		TimestampMillis: 20,
+		ClientID:        "client2",
+		ChangeID:        "rename",
+		Deps:            []string{"add"},
+	}))
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "remove", Path: "/items/[y]"}},
+		TimestampMillis: 30,
+		ClientID:        "client2",
+		ChangeID:        "remove",
+		Deps:            []string{"rename"},
+	}))
+
+	// the released change fails and is dropped, the dependency is applied
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "add", Path: "/items/0", Value: rawMessage(`{"id":"x","name":"a"}`)}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "add",
+	}))
+	assert.Equal(t, `{"items":[{"id":"x","name":"a"}]}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 2)
+	assert.Len(t, doc.Warnings(), 1)
+	assert.Equal(t, "rename", doc.Warnings()[0].ChangeID)
+	assert.Equal(t, PhasePatch, doc.Warnings()[0].Phase)
+	assert.Equal(t, -1, doc.Warnings()[0].OpIndex)
+
+	// changes, that depend on the dropped change, keep waiting
+	assert.Equal(t, "remove", doc.Pending()[0].ChangeID)
+	assert.Equal(t, []string{"rename"}, doc.MissingDeps())
+}
@@ -6,7 +6,6 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
-	"strings"
 )
 
 func diff(left, right []byte, identifiers [][]string) ([]Operation, error) {
@@ -21,11 +20,11 @@ func diff(left, right []byte, identifiers [][]string) ([]Operation, error) {
 		return nil, err
 	}
 
-	ops := compare([]Operation{}, "", l, r, identifiers)
+	ops := compare([]Operation{}, Path{}, l, r, identifiers)
 	return ops, nil
 }
 
-func compare(ops []Operation, path string, left, right any, identifiers [][]string) []Operation {
+func compare(ops []Operation, path Path, left, right any, identifiers [][]string) []Operation {
 	// if left and right nil, no changes
 	if left == nil && right == nil {
 		return ops
@@ -61,7 +60,7 @@ func compare(ops []Operation, path string, left, right any, identifiers [][]stri
 	return ops
 }
 
-func compareMaps(ops []Operation, path string, left, right map[string]any, identifiers [][]string) []Operation {
+func compareMaps(ops []Operation, path Path, left, right map[string]any, identifiers [][]string) []Operation {
 	// sort keys cosmetically only, as PigeonJS uses an alphabetical order.
 	leftKeys := make([]string, 0, len(left))
 	for key := range left {
@@ -79,7 +78,7 @@ func compareMaps(ops []Operation, path string, left, right map[string]any, ident
 	for _, key := range leftKeys {
 		leftVal := left[key]
 		rightVal, exists := right[key]
-		newPath := path + "/" + key
+		newPath := path.Append(Key(key))
 		if exists {
 			// compare values if the key exists in both objects
 			ops = compare(ops, newPath, leftVal, rightVal, identifiers)
@@ -97,7 +96,7 @@ func compareMaps(ops []Operation, path string, left, right map[string]any, ident
 			if rightVal == nil {
 				continue
 			}
-			newPath := path + "/" + key
+			newPath := path.Append(Key(key))
 			ops = append(ops, newChange(newPath, nil, rightVal))
 		}
 	}
@@ -117,7 +116,7 @@ func isSlicePrimitive(slice []any) bool {
 	return true
 }
 
-func comparePrimitiveSlices(ops []Operation, path string, left, right []any) []Operation {
+func comparePrimitiveSlices(ops []Operation, path Path, left, right []any) []Operation {
 	if !isSlicePrimitive(right) || len(left) != len(right) {
 		// replace all
 		ops = append(ops, newChange(path, left, right))
@@ -136,7 +135,7 @@ func comparePrimitiveSlices(ops []Operation, path string, left, right []any) []O
 	return ops
 }
 
-func compareSlices(ops []Operation, path string, left, right []any, identifiers [][]string) []Operation {
+func compareSlices(ops []Operation, path Path, left, right []any, identifiers [][]string) []Operation {
 	// is slice primitive, use only replace all operations
 	if isSlicePrimitive(left) {
 		ops = comparePrimitiveSlices(ops, path, left, right)
@@ -165,16 +164,16 @@ func compareSlices(ops []Operation, path string, left, right []any, identifiers
 			if rightIndex, exists := rightIDIndexMap[id]; exists {
 				// moved?
 				if leftIndex != rightIndex {
-					oldPath := path + "/" + getArrayItemID(leftVal, right[rightIndex], leftIndex, identifiers)
-					newPath := fmt.Sprintf("%s/%d", path, rightIndex)
+					oldPath := path.Append(getArrayItemSegment(leftVal, right[rightIndex], leftIndex, identifiers))
+					newPath := path.Append(Index(rightIndex))
 					ops = append(ops, addMove(oldPath, newPath))
 				}
 				handledRight[rightIndex] = true
-				newPath := path + "/" + getArrayItemID(leftVal, right[rightIndex], leftIndex, identifiers)
+				newPath := path.Append(getArrayItemSegment(leftVal, right[rightIndex], leftIndex, identifiers))
 				ops = compare(ops, newPath, leftVal, right[rightIndex], identifiers)
 			} else {
 				// remove
-				newPath := path + "/" + getArrayItemID(leftVal, nil, leftIndex, identifiers)
+				newPath := path.Append(getArrayItemSegment(leftVal, nil, leftIndex, identifiers))
 				ops = compare(ops, newPath, leftVal, nil, identifiers)
 			}
 		}
@@ -182,19 +181,18 @@ func compareSlices(ops []Operation, path string, left, right []any, identifiers
 
 	for rightIndex, rightVal := range right {
 		if _, handled := handledRight[rightIndex]; !handled {
-			newPath := fmt.Sprintf("%s/%d", path, rightIndex)
+			newPath := path.Append(Index(rightIndex))
 			switch rightVal.(type) {
 			case map[string]any:
 				op := newChange(newPath, nil, rightVal)
 
 				// support add before id like `/array/[id]`
 				if rightIndex < len(right)-1 {
-					nextID := getID(right[rightIndex+1], identifiers)
+					nextID := getRawID(right[rightIndex+1], identifiers)
 					// use the id at the path, if it exists
 					if nextID != "" {
 						if _, handledNextID := handledRight[rightIndex+1]; handledNextID {
-							pathParts := strings.Split(newPath, "/")
-							op.Path = fmt.Sprintf("%s/%s", strings.Join(pathParts[:len(pathParts)-1], "/"), nextID)
+							op.Path = path.Append(ID(nextID)).String()
 						}
 					}
 				}
@@ -208,6 +206,14 @@ func compareSlices(ops []Operation, path string, left, right []any, identifiers
 }
 
 func getID(value any, identifiers [][]string) string {
+	if id := getRawID(value, identifiers); id != "" {
+		return formatID(id)
+	}
+	return ""
+}
+
+// getRawID returns the ID of an object without brackets.
+func getRawID(value any, identifiers [][]string) string {
 	if m, ok := value.(map[string]any); ok {
 		for _, identifier := range identifiers {
 			layer := m
@@ -223,11 +229,11 @@ func getID(value any, identifiers [][]string) string {
 					case float64:
 						// is a int?
 						if v == float64(int64(v)) {
-							return formatID(fmt.Sprintf("%d", int64(v)))
+							return fmt.Sprintf("%d", int64(v))
 						}
-						return formatID(fmt.Sprintf("%f", v))
+						return fmt.Sprintf("%f", v)
 					case string:
-						return formatID(v)
+						return v
 					default:
 						return ""
 					}
@@ -245,26 +251,20 @@ func getID(value any, identifiers [][]string) string {
 	return ""
 }
 
-// getArrayItemID returns the ID of an array item.
-func getArrayItemID(left, right any, index int, identifiers [][]string) string {
+// getArrayItemSegment returns the ID segment of an array item or its index segment.
+func getArrayItemSegment(left, right any, index int, identifiers [][]string) Segment {
 	// check if the left object has an ID.
-	if leftMap, ok := left.(map[string]any); ok {
-		id := getID(leftMap, identifiers)
-		if id != "" {
-			return id
-		}
+	if id := getRawID(left, identifiers); id != "" {
+		return ID(id)
 	}
 
 	// check if the right object has an ID.
-	if rightMap, ok := right.(map[string]any); ok {
-		id := getID(rightMap, identifiers)
-		if id != "" {
-			return id
-		}
+	if id := getRawID(right, identifiers); id != "" {
+		return ID(id)
 	}
 
 	// use the index as ID.
-	return strconv.Itoa(index)
+	return Index(index)
 }
 
 // formatID formats an ID to a string with [<id>].
@@ -279,7 +279,7 @@ func formatID(id any) string {
 	}
 }
 
-func newChange(path string, left, right any) Operation {
+func newChange(path Path, left, right any) Operation {
 	var prevValue, newValue *json.RawMessage
 	var operation string
 
@@ -303,16 +303,16 @@ func newChange(path string, left, right any) Operation {
 
 	return Operation{
 		Op:    operation,
-		Path:  path,
+		Path:  path.String(),
 		Prev:  prevValue,
 		Value: newValue,
 	}
 }
 
-func addMove(fromPath, toPath string) Operation {
+func addMove(fromPath, toPath Path) Operation {
 	return Operation{
 		Op:   "move",
-		Path: toPath,
-		From: fromPath,
+		Path: toPath.String(),
+		From: fromPath.String(),
 	}
 }
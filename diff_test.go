@@ -120,7 +120,7 @@ func TestComparePrimitiveSlices(t *testing.T) {
 
 	for _, testCase := range testCases {
 		ops := []Operation{}
-		ops = comparePrimitiveSlices(ops, "/array", testCase.left, testCase.right)
+		ops = comparePrimitiveSlices(ops, Path{Key("array")}, testCase.left, testCase.right)
 
 		if testCase.expectedReplace {
 			rightBytes, _ := json.Marshal(testCase.right)
@@ -1,9 +1,10 @@
 package pigeongo
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"sort"
 	"strings"
 	"time"
 
@@ -39,19 +40,49 @@ func WithInitialMessageID(messageID string) DocumentOption {
 }
 
 type Document struct {
-	raw         []byte
-	history     []Change
-	changeIDs   map[string]int
-	stash       []Change
-	identifiers [][]string
+	// raw are the source bytes until the first patch
+	raw           []byte
+	root          *node
+	history       []Change
+	changeIDs     map[string]int
+	stash         []Change
+	identifiers   [][]string
+	historyLength int
+	clock         func() time.Time
+	hlc           *HLC
+	seqs          map[string]int
+	lenient       bool
+	checksums     bool
+	warnings      []Warning
+	maxHistory    int
+	maxHistoryAge time.Duration
+	undo          map[string]*undoState
+	observers     []observerEntry
+	observerID    int
+	recordEvents  bool
+	events        []Event
+
+	checkpointChanges int
+	checkpointBytes   int
+	checkpoints       []checkpoint
+	// pending changes wait for missing dependencies
+	pending []Change
+	// received is the arrival order of the applied changes for ChangesSince
+	received    map[string]int
+	receivedSeq int
 }
 
 func NewDocument(raw []byte, opts ...DocumentOption) (*Document, error) {
 	doc := &Document{
-		raw:         raw,
-		changeIDs:   map[string]int{},
-		stash:       []Change{},
-		identifiers: [][]string{{"id"}},
+		raw:           raw,
+		changeIDs:     map[string]int{},
+		received:      map[string]int{},
+		stash:         []Change{},
+		identifiers:   [][]string{{"id"}},
+		historyLength: defaultHistoryLength,
+		clock:         time.Now,
+		seqs:          map[string]int{},
+		undo:          map[string]*undoState{},
 	}
 
 	doc.history = []Change{
@@ -70,6 +101,7 @@ func NewDocument(raw []byte, opts ...DocumentOption) (*Document, error) {
 	if err := validateDuplicateIdentifiers(doc.raw, doc.identifiers); err != nil {
 		return nil, fmt.Errorf("error in identifiers: %s", err.Error())
 	}
+	doc.root = parseRoot(doc.raw)
 
 	return doc, nil
 }
@@ -84,7 +116,7 @@ func createInitialDiff(raw []byte) []Operation {
 		_, _ = jsonparser.ArrayEach(raw, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
 			diff = append(diff, Operation{
 				Op:    "add",
-				Path:  fmt.Sprintf("/%d", i),
+				Path:  Path{Index(i)}.String(),
 				Value: rawToJSON(value, dataType),
 			})
 			i++
@@ -93,7 +125,7 @@ func createInitialDiff(raw []byte) []Operation {
 		_ = jsonparser.ObjectEach(raw, func(key, value []byte, dataType jsonparser.ValueType, offset int) error {
 			diff = append(diff, Operation{
 				Op:    "add",
-				Path:  fmt.Sprintf("/%s", key),
+				Path:  Path{Key(string(key))}.String(),
 				Value: rawToJSON(value, dataType),
 			})
 			return nil
@@ -116,6 +148,10 @@ type Change struct {
 	Seq             int         `json:"seq"`
 	ChangeID        string      `json:"change_id"`
 	MessageID       string      `json:"msg_id,omitempty"`
+	// Deps are the changeIDs, that must be applied before this change
+	Deps []string `json:"deps,omitempty"`
+	// Checksum of the document after the change, see WithChecksums
+	Checksum *int64 `json:"crc,omitempty"`
 }
 
 func NewJsonpatchPatch(diff []Operation) jsonpatch.Patch {
@@ -130,10 +166,18 @@ type Operation struct {
 	From  string           `json:"from,omitempty"`
 	Value *json.RawMessage `json:"value,omitempty"`
 	Prev  *json.RawMessage `json:"_prev,omitempty"`
+	// Anchor is the original position of a moved or removed array item like
+	// `/items/[next]` or `/items/2`. It is set when the change is applied, like Prev.
+	Anchor string `json:"_anchor,omitempty"`
 }
 
+// JSON returns the document. It is serialized on the first call after a change.
 func (d *Document) JSON() []byte {
-	return d.raw
+	if d.raw != nil {
+		return d.raw
+	}
+
+	return d.root.JSON()
 }
 
 func (d *Document) History() []Change {
@@ -142,21 +186,46 @@ func (d *Document) History() []Change {
 
 func (d *Document) Clone() *Document {
 	clone := &Document{
-		raw:         make([]byte, len(d.raw)),
-		history:     make([]Change, len(d.history)),
-		stash:       make([]Change, len(d.stash)),
-		identifiers: make([][]string, len(d.identifiers)),
-		changeIDs:   map[string]int{},
+		raw:           d.raw,
+		root:          d.root,
+		history:       copyChanges(d.history),
+		stash:         copyChanges(d.stash),
+		identifiers:   make([][]string, len(d.identifiers)),
+		changeIDs:     map[string]int{},
+		received:      map[string]int{},
+		receivedSeq:   d.receivedSeq,
+		historyLength: d.historyLength,
+		clock:         d.clock,
+		hlc:           d.hlc,
+		seqs:          map[string]int{},
+		lenient:       d.lenient,
+		checksums:     d.checksums,
+		maxHistory:    d.maxHistory,
+		maxHistoryAge: d.maxHistoryAge,
+		undo:          map[string]*undoState{},
+
+		checkpointChanges: d.checkpointChanges,
+		checkpointBytes:   d.checkpointBytes,
+		checkpoints:       append([]checkpoint{}, d.checkpoints...),
+		pending:           copyChanges(d.pending),
 	}
 
-	copy(clone.raw, d.raw)
-	copy(clone.history, d.history)
-	copy(clone.stash, d.stash)
-
 	for a, b := range d.changeIDs {
 		clone.changeIDs[a] = b
 	}
 
+	for changeID, seq := range d.received {
+		clone.received[changeID] = seq
+	}
+
+	for clientID, seq := range d.seqs {
+		clone.seqs[clientID] = seq
+	}
+
+	for clientID, state := range d.undo {
+		clone.undo[clientID] = state.clone()
+	}
+
 	for i, identifiers := range d.identifiers {
 		clone.identifiers[i] = make([]string, len(identifiers))
 		copy(clone.identifiers[i], identifiers)
@@ -165,17 +234,39 @@ func (d *Document) Clone() *Document {
 	return clone
 }
 
+func copyChanges(changes []Change) []Change {
+	if changes == nil {
+		return nil
+	}
+
+	result := make([]Change, len(changes))
+	for i, change := range changes {
+		// operations are updated in place during fast forward
+		change.Diff = append([]Operation{}, change.Diff...)
+		result[i] = change
+	}
+	return result
+}
+
 // replaceByWorkingCopy overwrite all fields in this document (without identifiers)!
 func (d *Document) replaceByWorkingCopy(workingCopy *Document) {
 	d.raw = workingCopy.raw
+	d.root = workingCopy.root
 	d.history = workingCopy.history
 	d.stash = workingCopy.stash
 	d.changeIDs = workingCopy.changeIDs
+	d.received = workingCopy.received
+	d.receivedSeq = workingCopy.receivedSeq
+	d.seqs = workingCopy.seqs
+	d.warnings = workingCopy.warnings
+	d.checkpoints = workingCopy.checkpoints
+
+	d.notify(workingCopy.events)
 }
 
 // FastForwardChanges apply all changes from the stash. It change nothing, if one change failed.
 func (d *Document) FastForwardChanges() error {
-	workingCopy := d.Clone()
+	workingCopy := d.workingCopy()
 
 	if err := workingCopy.fastForwardChanges(); err != nil {
 		return err
@@ -185,9 +276,10 @@ func (d *Document) FastForwardChanges() error {
 	return nil
 }
 
-// RewindChanges rewind to a specific change. It change nothing, if one change failed.
+// RewindChanges rewind all changes ordered after a change with timestampMillis and
+// clientID, see CompareChanges. It change nothing, if one change failed.
 func (d *Document) RewindChanges(timestampMillis int64, clientID string) error {
-	workingCopy := d.Clone()
+	workingCopy := d.workingCopy()
 
 	if err := workingCopy.rewindChanges(timestampMillis, clientID); err != nil {
 		return err
@@ -199,166 +291,376 @@ func (d *Document) RewindChanges(timestampMillis int64, clientID string) error {
 
 // ApplyChange to the document. It change nothing, if one operation failed.
 func (d *Document) ApplyChange(change Change) error {
-	// skip change if changeID is processed
-	if _, ok := d.changeIDs[change.ChangeID]; ok {
-		return nil
+	return d.ApplyChanges([]Change{change})
+}
+
+// ApplyChanges applies many changes with a single rewind and fast forward. The
+// changes are sorted by timestamp and clientID. Changes with missing Deps are
+// held back until the dependencies arrive. It change nothing, if one change failed.
+func (d *Document) ApplyChanges(changes []Change) error {
+	_, err := d.ReceiveChanges(changes)
+	return err
+}
+
+// ReceiveChanges applies many changes like ApplyChanges and returns them with
+// the timestamps adjusted by the drift policy of WithHLC. A server relays the
+// returned changes, so that all replicas order them the same way.
+func (d *Document) ReceiveChanges(changes []Change) ([]Change, error) {
+	changes, err := d.receiveChanges(changes)
+	if err != nil {
+		return nil, err
 	}
 
-	workingCopy := d.Clone()
+	if err := d.applyChanges(changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (d *Document) applyChanges(changes []Change) error {
+	// skip changes if changeID is processed and hold back changes with missing dependencies
+	batch, pending := d.readyChanges(changes)
 
-	if err := workingCopy.rewindChanges(change.TimestampMillis, change.ClientID); err != nil {
-		return fmt.Errorf("patch error for changeID %s: %s", change.ChangeID, err)
+	// released pending changes are applied after the new changes one by one, so
+	// that a failing one doesn't block the change it waited for
+	wasPending := map[string]bool{}
+	for _, change := range d.pending {
+		wasPending[change.ChangeID] = true
 	}
 
-	// remove external _prev from change
-	// set prev value
-	for i := range change.Diff {
-		if change.Diff[i].Op == "add" {
-			change.Diff[i].Prev = nil
+	received, released := d.retimedChanges(changes), []Change{}
+	for _, change := range batch {
+		if wasPending[change.ChangeID] {
+			released = append(released, change)
 		} else {
-			change.Diff[i].Prev = workingCopy.getValue(change.Diff[i].Path)
+			received = append(received, change)
 		}
+	}
 
-		if change.Diff[i].Op == "remove" {
-			change.Diff[i].Value = nil
+	var warnings []Warning
+	if len(received) > 0 {
+		if err := d.applyBatch(received); err != nil {
+			return err
 		}
+		warnings = d.warnings
 	}
 
-	// apply
-	var err error
-	workingCopy.raw, err = patch(workingCopy.raw, change.Diff, workingCopy.identifiers)
-	if err != nil {
-		return fmt.Errorf("patch error: can't apply changeID %s: %s", change.ChangeID, err.Error())
+	sort.SliceStable(released, func(i, j int) bool {
+		return CompareChanges(released[i], released[j]) < 0
+	})
+	for _, change := range released {
+		// a dependency failed before
+		if !d.depsKnown(change, nil) {
+			pending = append(pending, change)
+			continue
+		}
+
+		if err := d.applyBatch([]Change{change}); err != nil {
+			warnings = append(warnings, Warning{ChangeID: change.ChangeID, Phase: PhasePatch, OpIndex: -1, Err: err})
+			continue
+		}
+		warnings = append(warnings, d.warnings...)
 	}
 
-	if err := validateDuplicateIdentifiers(workingCopy.raw, workingCopy.identifiers); err != nil {
-		return fmt.Errorf("patch error: can't apply changeID %s: %s", change.ChangeID, err.Error())
+	d.warnings = warnings
+	d.pending = pending
+	d.observeChanges(changes)
+	return nil
+}
+
+// applyBatch applies the changes with a single rewind and fast forward. It
+// change nothing, if one change failed.
+func (d *Document) applyBatch(batch []Change) error {
+	sort.SliceStable(batch, func(i, j int) bool {
+		return CompareChanges(batch[i], batch[j]) < 0
+	})
+
+	workingCopy := d.workingCopy()
+
+	// known changes of the batch are retimed and replace the own copy
+	first := batch[0]
+	retimed := map[string]bool{}
+	for _, change := range batch {
+		if _, ok := d.changeIDs[change.ChangeID]; !ok {
+			continue
+		}
+		retimed[change.ChangeID] = true
+		for _, own := range d.history[1:] {
+			if own.ChangeID == change.ChangeID && CompareChanges(own, first) < 0 {
+				first = own
+			}
+		}
 	}
 
-	workingCopy.changeIDs[change.ChangeID] = 1
+	changeID := batch[0].ChangeID
+	if err := workingCopy.rewindWhile(func(change Change) bool {
+		return rewindsBefore(change, first) || retimed[change.ChangeID]
+	}); err != nil {
+		return fmt.Errorf("patch error for changeID %s: %s", changeID, err)
+	}
+
+	if len(retimed) > 0 {
+		stash := make([]Change, 0, len(workingCopy.stash))
+		for _, change := range workingCopy.stash {
+			if !retimed[change.ChangeID] {
+				stash = append(stash, change)
+			}
+		}
+		workingCopy.stash = stash
+	}
+
+	// merge the new changes with the rewound changes
+	inserted := false
+	for _, change := range batch {
+		for len(workingCopy.stash) > 0 && !rewindsBefore(workingCopy.stash[len(workingCopy.stash)-1], change) {
+			if err := workingCopy.fastForwardChange(); err != nil {
+				return fmt.Errorf("patch error for changeID %s: %s", changeID, err)
+			}
+		}
+
+		changeID = change.ChangeID
+		ok, err := workingCopy.patchChange(change)
+		if err != nil {
+			return err
+		}
+		inserted = inserted || ok
+	}
 
 	if err := workingCopy.fastForwardChanges(); err != nil {
-		return fmt.Errorf("patch error for changeID %s: %s", change.ChangeID, err)
+		return fmt.Errorf("patch error for changeID %s: %s", changeID, err)
+	}
+
+	if inserted {
+		if err := workingCopy.limitHistory(); err != nil {
+			return fmt.Errorf("history error for changeID %s: %s", changeID, err)
+		}
+	}
+
+	workingCopy.verifyChecksums(batch)
+
+	d.replaceByWorkingCopy(workingCopy)
+	return nil
+}
+
+// patchChange applies a new change and inserts it into the history. It returns
+// false, if the change was skipped in lenient mode.
+func (d *Document) patchChange(change Change) (bool, error) {
+	// remove external _prev from change
+	// set prev value
+	d.setPrevValues(change.Diff)
+
+	// a failing test aborts the change
+	if index, err := d.failedTest(change.Diff); err != nil {
+		if !d.lenient {
+			return false, fmt.Errorf("patch error: can't apply changeID %s: %w", change.ChangeID, err)
+		}
+
+		d.warn(change.ChangeID, PhasePatch, index, err)
+		return false, nil
+	}
+
+	// apply
+	raw, root := d.raw, d.root
+	resolved, skipped, err := d.applyOperations(change.ChangeID, PhasePatch, change.Diff, false)
+	if err != nil {
+		return false, fmt.Errorf("patch error: can't apply changeID %s: %s", change.ChangeID, err.Error())
+	}
+	change.Diff = withoutOperations(change.Diff, skipped)
+
+	if err := validateChangedIdentifiers(d.root, root, d.identifiers); err != nil {
+		if !d.lenient {
+			return false, fmt.Errorf("patch error: can't apply changeID %s: %s", change.ChangeID, err.Error())
+		}
+
+		// skip the whole change
+		d.raw, d.root = raw, root
+		d.warn(change.ChangeID, PhasePatch, -1, err)
+		return false, nil
 	}
 
-	idx := len(workingCopy.history)
+	d.changeIDs[change.ChangeID] = 1
+	d.receivedSeq++
+	d.received[change.ChangeID] = d.receivedSeq
+	d.emit(EventApplied, change, resolved)
+	d.insertHistory(change)
+
+	return true, nil
+}
+
+// CompareChanges orders changes by TimestampMillis, ClientID, Seq and ChangeID.
+// It returns -1 if a is ordered before b, 1 if a is ordered after b and 0 for
+// the same change. All replicas order their history by this key, so they
+// converge to the same history independent of the arrival order.
+func CompareChanges(a, b Change) int {
+	switch {
+	case a.TimestampMillis != b.TimestampMillis:
+		return cmp.Compare(a.TimestampMillis, b.TimestampMillis)
+	case a.ClientID != b.ClientID:
+		return strings.Compare(a.ClientID, b.ClientID)
+	case a.Seq != b.Seq:
+		return cmp.Compare(a.Seq, b.Seq)
+	}
+	return strings.Compare(a.ChangeID, b.ChangeID)
+}
+
+// rewindsBefore returns true, if the change is rewound to apply next before it.
+func rewindsBefore(change Change, next Change) bool {
+	return CompareChanges(change, next) > 0
+}
+
+// insertHistory inserts the change by its timestamp into the history.
+func (d *Document) insertHistory(change Change) {
+	idx := len(d.history)
 	if idx == 0 {
-		workingCopy.history = append(workingCopy.history, change)
-		d.replaceByWorkingCopy(workingCopy)
-		return nil
+		d.history = append(d.history, change)
+		return
 	}
 
 	// find position to insert
-	for idx > 1 && workingCopy.history[idx-1].TimestampMillis > change.TimestampMillis {
+	for idx > 1 && CompareChanges(d.history[idx-1], change) > 0 {
 		idx--
 	}
 
 	// empty history or after last element
-	if len(workingCopy.history) == idx {
-		workingCopy.history = append(workingCopy.history, change)
-		d.replaceByWorkingCopy(workingCopy)
-		return nil
+	if len(d.history) == idx {
+		d.history = append(d.history, change)
+		d.addCheckpoint()
+		return
 	}
 
-	workingCopy.history = append(workingCopy.history[:idx+1], workingCopy.history[idx:]...)
-	workingCopy.history[idx] = change
-
-	d.replaceByWorkingCopy(workingCopy)
-	return nil
+	d.dropCheckpoints(idx)
+	d.history = append(d.history[:idx+1], d.history[idx:]...)
+	d.history[idx] = change
 }
 
+// ReduceHistory folds all changes older than minTimestampMillis into the initial diff.
 func (d *Document) ReduceHistory(minTimestampMillis int64) error {
 	if len(d.history) == 1 {
 		// only the initial diff, nothing to reduce
 		return nil
 	}
 
-	workingCopy := d.Clone()
+	workingCopy := d.workingCopy()
 
 	// rewind all changes that are newer the minimum timestamp
+	mark := len(workingCopy.events)
 	if err := workingCopy.rewindChanges(minTimestampMillis, ""); err != nil {
 		return err
 	}
 
-	// new first diff is the initial diff
-	newHistory := []Change{
-		{
-			Diff:            createInitialDiff(workingCopy.raw),
-			TimestampMillis: workingCopy.history[len(workingCopy.history)-1].TimestampMillis,
-			ClientID:        workingCopy.history[len(workingCopy.history)-1].ClientID,
-			ChangeID:        workingCopy.history[len(workingCopy.history)-1].ChangeID,
-			MessageID:       workingCopy.history[len(workingCopy.history)-1].MessageID,
-		},
+	if err := workingCopy.foldHistory(); err != nil {
+		return err
 	}
+	workingCopy.emitReduced(mark)
+
+	d.replaceByWorkingCopy(workingCopy)
+	return nil
+}
 
-	// reverse append the stash changes
-	for i := len(workingCopy.stash) - 1; i >= 0; i-- {
-		newHistory = append(newHistory, workingCopy.stash[i])
+// foldHistory replaces the history by a new initial diff of the rewound document
+// and fast forwards the stash.
+func (d *Document) foldHistory() error {
+	last := d.history[len(d.history)-1]
+
+	// new first diff is the initial diff
+	initial := Change{
+		Diff:            createInitialDiff(d.JSON()),
+		TimestampMillis: last.TimestampMillis,
+		ClientID:        last.ClientID,
+		ChangeID:        last.ChangeID,
+		MessageID:       last.MessageID,
 	}
 
 	// append all newer changes to history
-	if err := workingCopy.fastForwardChanges(); err != nil {
+	folded := len(d.history)
+	if err := d.fastForwardChanges(); err != nil {
 		return err
 	}
 
-	workingCopy.history = newHistory
-
-	d.replaceByWorkingCopy(workingCopy)
+	d.history = append([]Change{initial}, d.history[folded:]...)
+	d.foldCheckpoints(folded)
 	return nil
 }
 
 // fastForwardChanges will apply all changes in the stash. It will stop if a patch fails and reset nothing!
 func (d *Document) fastForwardChanges() error {
-	var err error
-	for i := len(d.stash) - 1; i >= 0; i-- {
-		change := d.stash[i]
-
-		// set prev value, maybe changed by patch before!
-		for i := range change.Diff {
-			if change.Diff[i].Op == "add" {
-				change.Diff[i].Prev = nil
-			} else {
-				change.Diff[i].Prev = d.getValue(change.Diff[i].Path)
-			}
-
-			if change.Diff[i].Op == "remove" {
-				change.Diff[i].Value = nil
-			}
+	for len(d.stash) > 0 {
+		if err := d.fastForwardChange(); err != nil {
+			return err
 		}
+	}
+	d.stash = []Change{}
 
-		d.raw, err = patch(d.raw, change.Diff, d.identifiers)
-		if err != nil {
-			return fmt.Errorf("fast forward error: can't patch changeID %s from stash: %s", change.ChangeID, err.Error())
-		}
+	return nil
+}
 
-		d.changeIDs[change.ChangeID] = 1
-		d.history = append(d.history, change)
+// fastForwardChange applies the oldest change of the stash again.
+func (d *Document) fastForwardChange() error {
+	change := d.stash[len(d.stash)-1]
+
+	// set prev value, maybe changed by patch before!
+	d.setPrevValues(change.Diff)
+
+	// the tests are evaluated again, a change is dropped if they don't hold anymore
+	if index, err := d.failedTest(change.Diff); err != nil {
+		d.warn(change.ChangeID, PhaseFastForward, index, err)
+		d.stash = d.stash[:len(d.stash)-1]
+		return nil
 	}
-	d.stash = []Change{}
+
+	resolved, skipped, err := d.applyOperations(change.ChangeID, PhaseFastForward, change.Diff, false)
+	if err != nil {
+		return fmt.Errorf("fast forward error: can't patch changeID %s from stash: %s", change.ChangeID, err.Error())
+	}
+	change.Diff = withoutOperations(change.Diff, skipped)
+	d.emit(EventFastForwarded, change, resolved)
+
+	d.changeIDs[change.ChangeID] = 1
+	d.history = append(d.history, change)
+	d.stash = d.stash[:len(d.stash)-1]
+	d.addCheckpoint()
 
 	return nil
 }
 
 // rewindChanges will rewind all changes in the history. It will stop if a patch fails and reset nothing!
 func (d *Document) rewindChanges(timestampMillis int64, clientID string) error {
-	for len(d.history) > 1 {
-		change := d.history[len(d.history)-1]
-		if change.TimestampMillis > timestampMillis || (change.TimestampMillis == timestampMillis && change.ClientID > clientID) {
-			// get element and pop from history
-			c := d.history[len(d.history)-1]
-			d.history = d.history[:len(d.history)-1]
-
-			var err error
-			d.raw, err = patch(d.raw, reverse(c.Diff, d.identifiers), d.identifiers)
-			if err != nil {
-				return fmt.Errorf("rewind error: can't reverse patch changeID %s from history: %s", change.ChangeID, err.Error())
-			}
+	return d.rewindWhile(func(change Change) bool {
+		return rewindsBefore(change, Change{TimestampMillis: timestampMillis, ClientID: clientID})
+	})
+}
 
-			delete(d.changeIDs, c.ChangeID)
-			d.stash = append(d.stash, c)
-			continue
+// rewindWhile rewinds the newest changes of the history as long as rewind returns true.
+// The initial diff is never rewound. It will stop if a patch fails and reset nothing!
+func (d *Document) rewindWhile(rewind func(change Change) bool) error {
+	length := len(d.history)
+	for length > 1 && rewind(d.history[length-1]) {
+		length--
+	}
+
+	if ok, err := d.rewindCheckpoint(length); err != nil {
+		return fmt.Errorf("rewind error: can't restore checkpoint: %s", err.Error())
+	} else if ok {
+		return nil
+	}
+
+	for len(d.history) > length {
+		// get element and pop from history
+		c := d.history[len(d.history)-1]
+		d.history = d.history[:len(d.history)-1]
+		d.dropCheckpoints(len(d.history))
+
+		resolved, skipped, err := d.applyOperations(c.ChangeID, PhaseRewind, reverse(c.Diff, d.identifiers), true)
+		if err != nil {
+			return fmt.Errorf("rewind error: can't reverse patch changeID %s from history: %s", c.ChangeID, err.Error())
 		}
-		break
+		// the effect of not reversed operations is still in the document
+		c.Diff = withoutOperations(c.Diff, skipped)
+		d.emit(EventRewound, c, resolved)
+
+		delete(d.changeIDs, c.ChangeID)
+		d.stash = append(d.stash, c)
 	}
 
 	return nil
@@ -402,40 +704,18 @@ func rawMessage(s string) *json.RawMessage {
 	return &raw
 }
 
+// getValue returns the value at path. Array items are addressed by index or
+// identifier, objects by key.
 func (d *Document) getValue(path string) *json.RawMessage {
-	if d.raw == nil {
-		return nil
-	}
-
-	jsonpatchPath, err := replacePath(d.raw, path, d.identifiers)
-	if err != nil {
+	current, ok := d.lookup(path)
+	if !ok || path == "" {
 		return nil
 	}
 
-	jsonparserPath := []string{}
-
-	pathParts := strings.Split(jsonpatchPath, "/")
-	if len(pathParts) < 2 || pathParts[0] != "" {
-		return nil
-	}
-
-	for _, part := range pathParts {
-		if part == "" {
-			continue
-		}
-
-		if _, err := strconv.Atoi(part); err == nil {
-			jsonparserPath = append(jsonparserPath, fmt.Sprintf(`[%s]`, part))
-			continue
-		}
-
-		jsonparserPath = append(jsonparserPath, part)
-	}
-
-	value, dataType, _, err := jsonparser.Get(d.raw, jsonparserPath...)
-	if err != nil {
-		return nil
+	// the source bytes are returned until the first patch
+	if d.raw != nil {
+		return rawMessage(string(current.raw))
 	}
 
-	return rawToJSON(value, dataType)
+	return rawMessage(string(current.JSON()))
 }
@@ -95,7 +95,7 @@ func TestApplyChange(t *testing.T) {
 	})
 
 	assert.Equal(t, `{"name":"Phil"}`, string(doc.JSON()))
-	assert.Equal(t, "patch error: can't apply changeID hhde2ffcgj: error in remove for path: '/notexist': Unable to remove nonexistent key: notexist: missing value", err.Error())
+	assert.Equal(t, "patch error: can't apply changeID hhde2ffcgj: error in remove for path: '/notexist': unable to remove nonexistent key: notexist: missing value", err.Error())
 
 	_, err = json.Marshal(doc.History())
 	assert.NoError(t, err)
@@ -697,6 +697,148 @@ func TestApplyChangesWithNull(t *testing.T) {
 	assert.Equal(t, `{"abc":null,"foo":null,"hello":null,"test":123}`, string(doc.JSON()))
 }
 
+func TestApplyChanges(t *testing.T) {
+	t.Parallel()
+
+	changes := func() []Change {
+		return []Change{
+			{
+				Diff:            []Operation{{Op: "replace", Path: "/cards/[card1]/text", Value: rawMessage(`"baz"`)}},
+				TimestampMillis: 30,
+				ClientID:        "client1",
+				ChangeID:        "change3",
+			},
+			{
+				Diff:            []Operation{{Op: "add", Path: "/cards/1", Value: rawMessage(`{"id":"card2","text":"bar"}`)}},
+				TimestampMillis: 10,
+				ClientID:        "client2",
+				ChangeID:        "change1",
+			},
+			{
+				Diff:            []Operation{{Op: "add", Path: "/title", Value: rawMessage(`"Board"`)}},
+				TimestampMillis: 20,
+				ClientID:        "client1",
+				ChangeID:        "change2",
+			},
+			{
+				Diff:            []Operation{{Op: "replace", Path: "/title", Value: rawMessage(`"Team"`)}},
+				TimestampMillis: 20,
+				ClientID:        "client2",
+				ChangeID:        "change4",
+			},
+		}
+	}
+
+	existing := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/cards/[card1]/text", Value: rawMessage(`"qux"`)}},
+		TimestampMillis: 15,
+		ClientID:        "client3",
+		ChangeID:        "change5",
+	}
+
+	batched, err := NewDocument([]byte(`{"cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, batched.ApplyChange(existing))
+	assert.Nil(t, batched.ApplyChanges(changes()))
+
+	sequential, err := NewDocument([]byte(`{"cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, sequential.ApplyChange(existing))
+	for _, change := range changes() {
+		assert.Nil(t, sequential.ApplyChange(change))
+	}
+
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"},{"id":"card2","text":"bar"}],"title":"Team"}`, string(batched.JSON()))
+	assert.Equal(t, string(sequential.JSON()), string(batched.JSON()))
+
+	ids := []string{}
+	for _, change := range batched.History() {
+		ids = append(ids, change.ChangeID)
+	}
+	assert.Equal(t, []string{"0", "change1", "change5", "change2", "change4", "change3"}, ids)
+
+	// known and duplicated changes are skipped
+	assert.Nil(t, batched.ApplyChanges(append(changes(), changes()...)))
+	assert.Len(t, batched.History(), 6)
+}
+
+func TestApplyChangesFailed(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp"}`))
+	assert.Nil(t, err)
+
+	err = doc.ApplyChanges([]Change{
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"Phil"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client1",
+			ChangeID:        "change1",
+		},
+		{
+			Diff:            []Operation{{Op: "remove", Path: "/email"}},
+			TimestampMillis: 20,
+			ClientID:        "client1",
+			ChangeID:        "change2",
+		},
+	})
+	assert.EqualError(t, err, "patch error: can't apply changeID change2: error in remove for path: '/email': unable to remove nonexistent key: email: missing value")
+
+	// nothing changed
+	assert.Equal(t, `{"name":"Philipp"}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 1)
+}
+
+func TestCompareChanges(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		a    Change
+		b    Change
+		want int
+	}{
+		{a: Change{TimestampMillis: 1, ClientID: "b"}, b: Change{TimestampMillis: 2, ClientID: "a"}, want: -1},
+		{a: Change{TimestampMillis: 2, ClientID: "a"}, b: Change{TimestampMillis: 2, ClientID: "b"}, want: -1},
+		{a: Change{TimestampMillis: 2, ClientID: "a", Seq: 2, ChangeID: "a"}, b: Change{TimestampMillis: 2, ClientID: "a", Seq: 1, ChangeID: "b"}, want: 1},
+		{a: Change{TimestampMillis: 2, ClientID: "a", Seq: 1, ChangeID: "b"}, b: Change{TimestampMillis: 2, ClientID: "a", Seq: 1, ChangeID: "a"}, want: 1},
+		{a: Change{TimestampMillis: 2, ClientID: "a", Seq: 1, ChangeID: "a"}, b: Change{TimestampMillis: 2, ClientID: "a", Seq: 1, ChangeID: "a"}, want: 0},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.want, CompareChanges(testCase.a, testCase.b))
+		assert.Equal(t, -testCase.want, CompareChanges(testCase.b, testCase.a))
+	}
+}
+
+func TestConvergingOrder(t *testing.T) {
+	t.Parallel()
+
+	// all changes in the same millisecond
+	changes := []Change{
+		{Diff: []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"a"`)}}, TimestampMillis: 10, ClientID: "client1", Seq: 1, ChangeID: "x"},
+		{Diff: []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"b"`)}}, TimestampMillis: 10, ClientID: "client1", Seq: 2, ChangeID: "w"},
+		{Diff: []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"c"`)}}, TimestampMillis: 10, ClientID: "client1", Seq: 2, ChangeID: "y"},
+		{Diff: []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"d"`)}}, TimestampMillis: 10, ClientID: "client0", Seq: 9, ChangeID: "z"},
+	}
+
+	orders := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}, {1, 3, 0, 2}}
+	for _, order := range orders {
+		doc, err := NewDocument([]byte(`{"name":""}`))
+		assert.Nil(t, err)
+
+		for _, i := range order {
+			assert.Nil(t, doc.ApplyChange(changes[i]))
+		}
+
+		ids := []string{}
+		for _, change := range doc.History()[1:] {
+			ids = append(ids, change.ChangeID)
+		}
+		assert.Equal(t, []string{"z", "x", "w", "y"}, ids, order)
+		assert.Equal(t, `{"name":"c"}`, string(doc.JSON()), order)
+	}
+}
+
 func TestWrongPrev(t *testing.T) {
 	t.Parallel()
 
@@ -804,6 +946,12 @@ func TestGetValue(t *testing.T) {
 	assert.Equal(t, `["one", "two"]`, string(*doc.getValue("/array")))
 	assert.Equal(t, `"bar"`, string(*doc.getValue("/object/foo")))
 	assert.Equal(t, `"baa"`, string(*doc.getValue("/complex/[1234]/foo")))
+
+	doc, err = NewDocument([]byte(`{"a/b": {"c~d": [{"id": "x/y]", "foo": "baa"}]}}`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, `"baa"`, string(*doc.getValue("/a~1b/c~0d/[x~1y]]/foo")))
+	assert.Nil(t, doc.getValue("/a/b/c~d/0/foo"))
 }
 
 func TestDiff(t *testing.T) {
@@ -828,6 +976,89 @@ func TestDiff(t *testing.T) {
 	assert.Equal(t, string(sortKeys(rawB)), string(sortKeys(docA.JSON())))
 }
 
+func TestEscapedPaths(t *testing.T) {
+	t.Parallel()
+
+	rawA := []byte(`{"https://example.com/a":{"a~b":1},"files":[{"id":"src/main.go","size":1},{"id":"a]b","size":2},{"id":"x/y","size":3}]}`)
+	rawB := []byte(`{"https://example.com/a":{"a~b":2,"~1":true},"files":[{"id":"src/main.go","size":4},{"id":"docs/~readme","size":5},{"id":"x/y","size":3}]}`)
+	docA, errA := NewDocument(rawA)
+	docB, errB := NewDocument(rawB)
+	assert.Nil(t, errA)
+	assert.Nil(t, errB)
+
+	change, err := docA.Diff(docB)
+	assert.Nil(t, err)
+	change.TimestampMillis = 20
+	change.ClientID = "client1"
+	change.ChangeID = "change1"
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "/files/[src~1main.go]/size", Value: rawMessage("4"), Prev: rawMessage("1")},
+		{Op: "remove", Path: "/files/[a]b]", Prev: rawMessage(`{"id":"a]b","size":2}`)},
+		{Op: "add", Path: "/files/[x~1y]", Value: rawMessage(`{"id":"docs/~readme","size":5}`)},
+		{Op: "replace", Path: "/https:~1~1example.com~1a/a~0b", Value: rawMessage("2"), Prev: rawMessage("1")},
+		{Op: "add", Path: "/https:~1~1example.com~1a/~01", Value: rawMessage("true")},
+	}, change.Diff)
+
+	assert.Nil(t, docA.ApplyChange(change))
+	assert.Equal(t, string(sortKeys(rawB)), string(sortKeys(docA.JSON())))
+
+	// the late change rewinds the escaped operations
+	assert.Nil(t, docA.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/files/[x~1y]/size", Value: rawMessage("6"), Prev: rawMessage("3")}},
+		TimestampMillis: 10,
+		ClientID:        "client2",
+		ChangeID:        "change2",
+	}))
+	assert.Nil(t, docA.Warnings())
+	assert.JSONEq(t, `{"https://example.com/a":{"a~b":2,"~1":true},"files":[{"id":"src/main.go","size":4},{"id":"docs/~readme","size":5},{"id":"x/y","size":6}]}`, string(docA.JSON()))
+
+	blame, err := docA.Blame("/files/[docs~1~0readme]/size")
+	assert.Nil(t, err)
+	assert.Equal(t, "change1", blame.ChangeID)
+	blame, err = docA.Blame("/files/2/size")
+	assert.Nil(t, err)
+	assert.Equal(t, "change2", blame.ChangeID)
+}
+
+func TestEscapedInitialPaths(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"a/b":1,"c~d":2}`)
+	testCases := []struct {
+		name string
+		opts []DocumentOption
+	}{
+		{name: "initial"},
+		{name: "folded", opts: []DocumentOption{WithMaxHistory(1)}},
+	}
+
+	for _, testCase := range testCases {
+		doc, err := NewDocument(raw, testCase.opts...)
+		assert.Nil(t, err)
+		if len(testCase.opts) > 0 {
+			assert.Nil(t, doc.ApplyChange(Change{
+				Diff:            []Operation{{Op: "add", Path: "/e", Value: rawMessage("3")}},
+				TimestampMillis: 10,
+				ClientID:        "client1",
+				ChangeID:        "change1",
+			}))
+		}
+
+		initial := doc.History()[0]
+		assert.Equal(t, "/a~1b", initial.Diff[0].Path, testCase.name)
+		assert.Equal(t, "/c~0d", initial.Diff[1].Path, testCase.name)
+
+		blame, err := doc.Blame("/a~1b")
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, initial.ChangeID, blame.ChangeID, testCase.name)
+
+		blameMap, err := doc.BlameMap()
+		assert.Nil(t, err, testCase.name)
+		assert.Contains(t, blameMap, "/a~1b", testCase.name)
+		assert.Contains(t, blameMap, "/c~0d", testCase.name)
+	}
+}
+
 // sortKeys for the assert functions
 func sortKeys(doc []byte) []byte {
 	obj := map[string]interface{}{}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"slices"
+	"strconv"
+	"strings"
 )
 
 func validateDuplicateIdentifiers(doc []byte, identifiers [][]string) error {
@@ -59,3 +61,122 @@ func walkValidateDuplicateIdentifiers(data any, currentPath string, identifiers
 
 	return nil
 }
+
+// validateChangedIdentifiers validates only the parts of the tree, that changed
+// since the previous version. The previous version must be valid.
+func validateChangedIdentifiers(root, previous *node, identifiers [][]string) error {
+	if len(identifiers) == 0 {
+		return nil
+	}
+
+	return walkChangedIdentifiers(root, previous, nil, identifiers)
+}
+
+// walkChangedIdentifiers collects the path segments and formats them only for
+// an error, most walked items are valid.
+func walkChangedIdentifiers(current, previous *node, path []string, identifiers [][]string) error {
+	if current == previous {
+		return nil
+	}
+
+	switch current.kind {
+	case kindObject:
+		fields, _ := current.children()
+		var previousFields map[string]*node
+		if previous != nil && previous.kind == kindObject {
+			previousFields, _ = previous.children()
+		}
+
+		for key, value := range fields {
+			if err := walkChangedIdentifiers(value, previousFields[key], append(path, key), identifiers); err != nil {
+				return err
+			}
+		}
+	case kindArray:
+		_, items := current.children()
+		var previousItems []*node
+		if previous != nil && previous.kind == kindArray {
+			_, previousItems = previous.children()
+		}
+
+		// the unchanged items at the start and the end are valid among each other
+		front, back := 0, 0
+		for front < len(items) && front < len(previousItems) && items[front] == previousItems[front] {
+			front++
+		}
+		for back < len(items)-front && back < len(previousItems)-front && items[len(items)-1-back] == previousItems[len(previousItems)-1-back] {
+			back++
+		}
+
+		var index, previousIndex map[string]int
+		for i := front; i < len(items)-back; i++ {
+			item := items[i]
+			switch item.kind {
+			case kindObject:
+				if index == nil {
+					index = current.validationIndex(identifiers)
+				}
+
+				id := item.validationID(identifiers)
+				if index[id] < 0 {
+					return duplicateIdentifierError(items, id, path, identifiers)
+				}
+
+				var previousItem *node
+				if len(previousItems) > 0 {
+					if previousIndex == nil {
+						previousIndex = previous.validationIndex(identifiers)
+					}
+					if position, ok := previousIndex[id]; ok && position >= 0 {
+						previousItem = previousItems[position]
+					}
+				}
+
+				if err := walkChangedIdentifiers(item, previousItem, append(path, id), identifiers); err != nil {
+					return err
+				}
+			case kindArray:
+				var previousItem *node
+				if i < len(previousItems) {
+					previousItem = previousItems[i]
+				}
+
+				if err := walkChangedIdentifiers(item, previousItem, append(path, strconv.Itoa(i)), identifiers); err != nil {
+					return err
+				}
+			default:
+				// values contain no identifiers
+			}
+		}
+	default:
+		// values contain no identifiers
+	}
+
+	return nil
+}
+
+// duplicateIdentifierError reports the second item with the identifier like
+// walkValidateDuplicateIdentifiers.
+func duplicateIdentifierError(items []*node, id string, path []string, identifiers [][]string) error {
+	position, found := 0, false
+	for i, item := range items {
+		if item.kind != kindObject || item.validationID(identifiers) != id {
+			continue
+		}
+		if found {
+			position = i
+			break
+		}
+		found = true
+	}
+
+	if id == "" {
+		id = "missing id"
+	}
+
+	currentPath := ""
+	if len(path) > 0 {
+		currentPath = "/" + strings.Join(path, "/")
+	}
+	return fmt.Errorf("duplicate identifier found: id `%s` at path %s/%d", id, currentPath, position)
+}
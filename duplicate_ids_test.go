@@ -1,6 +1,9 @@
 package pigeongo
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -225,3 +228,79 @@ func TestWalkValidateDuplicateIdentifiers(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateChangedIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"items":[{"id":"1","children":[{"id":"a"}]},{"id":"2"},{"id":"3"}],"lists":[[{"id":"x"}]]}`
+	testCases := []struct {
+		name       string
+		operations string
+		errorMsg   string
+	}{
+		{"replace", `[{"op":"replace","path":"/items/1/id","value":"4"}]`, ""},
+		{"move", `[{"op":"move","from":"/items/0","path":"/items/-"}]`, ""},
+		{"insert duplicate", `[{"op":"add","path":"/items/0","value":{"id":"3"}}]`, "duplicate identifier found: id `[3]` at path /items/3"},
+		{"append duplicate", `[{"op":"add","path":"/items/-","value":{"id":"1"}}]`, "duplicate identifier found: id `[1]` at path /items/3"},
+		{"replace duplicate", `[{"op":"replace","path":"/items/2/id","value":"2"}]`, "duplicate identifier found: id `[2]` at path /items/2"},
+		{"nested duplicate", `[{"op":"add","path":"/items/0/children/-","value":{"id":"a"}}]`, "duplicate identifier found: id `[a]` at path /items/[1]/children/1"},
+		{"nested array", `[{"op":"add","path":"/lists/0/0","value":{"id":"x"}}]`, "duplicate identifier found: id `[x]` at path /lists/0/1"},
+		{"missing ids", `[{"op":"add","path":"/lists/-","value":[{},{}]}]`, "duplicate identifier found: id `missing id` at path /lists/1/1"},
+	}
+
+	identifiers := [][]string{{"id"}}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var operations []Operation
+			assert.Nil(t, json.Unmarshal([]byte(testCase.operations), &operations))
+
+			previous := parseRoot([]byte(doc))
+			current, _, err := patchTree(previous, operations, identifiers)
+			assert.Nil(t, err)
+
+			// the changed parts are validated like the whole document
+			err = validateChangedIdentifiers(current, previous, identifiers)
+			if testCase.errorMsg == "" {
+				assert.Nil(t, err)
+				assert.Nil(t, validateDuplicateIdentifiers(current.JSON(), identifiers))
+			} else {
+				assert.EqualError(t, err, testCase.errorMsg)
+				assert.EqualError(t, validateDuplicateIdentifiers(current.JSON(), identifiers), testCase.errorMsg)
+			}
+		})
+	}
+}
+
+func BenchmarkValidateChangedIdentifiers(b *testing.B) {
+	items := make([]string, 20_000)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id":"item%d","text":"foo"}`, i)
+	}
+	identifiers := [][]string{{"id"}}
+
+	for _, op := range []string{"replace", "add"} {
+		b.Run(op, func(b *testing.B) {
+			root := parseRoot([]byte(`{"items":[` + strings.Join(items, ",") + `]}`))
+			for i := 0; i < b.N; i++ {
+				operation := Operation{Op: "replace", Path: fmt.Sprintf("/items/[item%d]/text", i%len(items)), Value: rawMessage(`"bar"`)}
+				if op == "add" {
+					operation = Operation{Op: "add", Path: "/items/-", Value: rawMessage(fmt.Sprintf(`{"id":"new%d"}`, i))}
+				}
+
+				b.StopTimer()
+				current, _, err := patchTree(root, []Operation{operation}, identifiers)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				if err := validateChangedIdentifiers(current, root, identifiers); err != nil {
+					b.Fatal(err)
+				}
+				root = current
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Get returns the compacted value at path. Paths use the syntax of the changes
+// like `/users/[u1]/name` or `/users/0/name`, the empty path is the document.
+func (d *Document) Get(path string) (json.RawMessage, bool) {
+	n, ok := d.lookup(path)
+	if !ok {
+		return nil, false
+	}
+
+	// the value must not share memory with the document
+	return append(json.RawMessage{}, n.JSON()...), true
+}
+
+// Exists returns true, if a value exists at path.
+func (d *Document) Exists(path string) bool {
+	_, ok := d.lookup(path)
+	return ok
+}
+
+// GetAs unmarshals the value at path into T. It returns ErrPathNotFound, if no
+// value exists at path.
+func GetAs[T any](d *Document, path string) (T, error) {
+	var value T
+
+	raw, ok := d.Get(path)
+	if !ok {
+		return value, ErrPathNotFound
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, fmt.Errorf("get error: path %s: %s", path, err.Error())
+	}
+
+	return value, nil
+}
+
+// lookup returns the node at path. Array items are addressed by index or identifier.
+func (d *Document) lookup(path string) (*node, bool) {
+	if d.root.kind == kindInvalid {
+		return nil, false
+	}
+
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	current := d.root
+	for _, segment := range p {
+		child, _, ok := d.child(current, segment)
+		if !ok {
+			return nil, false
+		}
+		current = child
+	}
+
+	return current, true
+}
+
+// child returns the child of an object by its key or of an array by its index
+// or identifier. The index is -1 for objects.
+func (d *Document) child(n *node, segment Segment) (*node, int, bool) {
+	switch n.kind {
+	case kindObject:
+		child, ok := n.field(segment.text())
+		return child, -1, ok
+	case kindArray:
+		_, items := n.children()
+
+		index := segment.Index
+		switch segment.Kind {
+		case SegmentID:
+			var ok bool
+			if index, ok = n.indexOf(segment.ID, d.identifiers); !ok {
+				return nil, 0, false
+			}
+		case SegmentIndex:
+			if index >= len(items) {
+				return nil, 0, false
+			}
+		default:
+			return nil, 0, false
+		}
+		return items[index], index, true
+	default:
+		return nil, 0, false
+	}
+}
@@ -0,0 +1,92 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{
+		"title": "Board",
+		"users": [{"id": "u1", "name": "Alice", "tags": ["a", "b"]}, {"id": "u2", "name": "Bob"}, {"id": "x/y]", "name": "Eve"}],
+		"a/b": {"c~d": 1},
+		"0": null
+	}`))
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{path: "", want: `{"title":"Board","users":[{"id":"u1","name":"Alice","tags":["a","b"]},{"id":"u2","name":"Bob"},{"id":"x/y]","name":"Eve"}],"a/b":{"c~d":1},"0":null}`, ok: true},
+		{path: "/title", want: `"Board"`, ok: true},
+		{path: "/users/[u2]/name", want: `"Bob"`, ok: true},
+		{path: "/users/0/name", want: `"Alice"`, ok: true},
+		{path: "/users/[u1]/tags/1", want: `"b"`, ok: true},
+		{path: "/users/[u1]", want: `{"id":"u1","name":"Alice","tags":["a","b"]}`, ok: true},
+		{path: "/a~1b/c~0d", want: `1`, ok: true},
+		{path: "/0", want: `null`, ok: true},
+		{path: "/users/[u3]/name"},
+		{path: "/users/[x~1y]]/name", want: `"Eve"`, ok: true},
+		{path: "/users/[x/y]]/name"},
+		{path: "/users/3"},
+		{path: "/users/-"},
+		{path: "/users/01"},
+		{path: "/users/-1"},
+		{path: "/users/0/email"},
+		{path: "/title/0"},
+		{path: "/0/x"},
+		{path: "title"},
+	}
+
+	for _, testCase := range testCases {
+		got, ok := doc.Get(testCase.path)
+		assert.Equal(t, testCase.ok, ok, testCase.path)
+		assert.Equal(t, testCase.ok, doc.Exists(testCase.path), testCase.path)
+		if testCase.ok {
+			assert.Equal(t, testCase.want, string(got), testCase.path)
+		}
+	}
+
+	// values are copies
+	got, _ := doc.Get("/title")
+	got[1] = 'X'
+	got, _ = doc.Get("/title")
+	assert.Equal(t, `"Board"`, string(got))
+}
+
+func TestGetAs(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"users":[{"id":"u1","name":"Alice","age":30}]}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/users/[u1]/age", Value: rawMessage("31")}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+
+	age, err := GetAs[int](doc, "/users/[u1]/age")
+	assert.Nil(t, err)
+	assert.Equal(t, 31, age)
+
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	u, err := GetAs[user](doc, "/users/[u1]")
+	assert.Nil(t, err)
+	assert.Equal(t, user{ID: "u1", Name: "Alice"}, u)
+
+	_, err = GetAs[int](doc, "/users/[u2]/age")
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = GetAs[int](doc, "/users/[u1]/name")
+	assert.EqualError(t, err, "get error: path /users/[u1]/name: json: cannot unmarshal string into Go value of type int")
+}
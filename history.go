@@ -0,0 +1,65 @@
+package pigeongo
+
+import "time"
+
+// WithMaxHistory limits the history to the newest changes, like PigeonJS
+// `setHistoryLength`. Older changes are folded into the initial diff by
+// ApplyChange, the same way as ReduceHistory does. A folding keeps the newest
+// half of the changes, so the history isn't folded on every change. It applies
+// to documents loaded by LoadPigeonJS, too.
+func WithMaxHistory(changes int) DocumentOption {
+	return func(d *Document) {
+		d.maxHistory = changes
+	}
+}
+
+// WithMaxHistoryAge limits the history to changes newer than maxAge. Older
+// changes are folded into the initial diff by ApplyChange, the same way as
+// ReduceHistory does. The age is measured with the clock of WithClock.
+func WithMaxHistoryAge(maxAge time.Duration) DocumentOption {
+	return func(d *Document) {
+		d.maxHistoryAge = maxAge
+	}
+}
+
+// limitHistory folds the oldest changes into the initial diff, if the history exceeds the limits.
+func (d *Document) limitHistory() error {
+	keep := len(d.history) - 1
+
+	if d.maxHistory > 0 && keep > d.maxHistory {
+		keep = d.maxHistory / 2
+	}
+
+	if d.maxHistoryAge > 0 {
+		minTimestampMillis := d.clock().Add(-d.maxHistoryAge).UnixMilli()
+
+		newer := 0
+		for i := len(d.history) - 1; i > 0 && d.history[i].TimestampMillis >= minTimestampMillis; i-- {
+			newer++
+		}
+
+		if newer < keep {
+			keep = newer
+		}
+	}
+
+	if keep == len(d.history)-1 {
+		return nil
+	}
+
+	mark := len(d.events)
+	rewound := 0
+	if err := d.rewindWhile(func(Change) bool {
+		rewound++
+		return rewound <= keep
+	}); err != nil {
+		return err
+	}
+
+	if err := d.foldHistory(); err != nil {
+		return err
+	}
+	d.emitReduced(mark)
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package pigeongo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxHistory(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"count":0}`), WithMaxHistory(4))
+	assert.Nil(t, err)
+
+	replace := func(i int, timestampMillis int64) Change {
+		return Change{
+			Diff: []Operation{
+				{
+					Op:    "replace",
+					Path:  "/count",
+					Value: rawMessage(fmt.Sprintf("%d", i)),
+				},
+			},
+			TimestampMillis: timestampMillis,
+			ClientID:        "client1",
+			ChangeID:        fmt.Sprintf("change%d", i),
+		}
+	}
+
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, doc.ApplyChange(replace(i, int64(i*10))))
+	}
+
+	// the history is folded to the newest half of the changes
+	assert.Equal(t, `{"count":5}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 3)
+	assert.Equal(t, "change3", doc.History()[0].ChangeID)
+	assert.Equal(t, []Operation{{Op: "add", Path: "/count", Value: rawMessage("3")}}, doc.History()[0].Diff)
+	assert.Equal(t, "change4", doc.History()[1].ChangeID)
+	assert.Equal(t, "change5", doc.History()[2].ChangeID)
+
+	// folded changes are still known
+	change := replace(100, 10)
+	change.ChangeID = "change1"
+	assert.Nil(t, doc.ApplyChange(change))
+	assert.Equal(t, `{"count":5}`, string(doc.JSON()))
+
+	// late changes are kept until the history exceeds the limit again
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/late",
+				Value: rawMessage("true"),
+			},
+		},
+		TimestampMillis: 35,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}))
+	assert.Equal(t, `{"count":5,"late":true}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 4)
+	assert.Equal(t, "late", doc.History()[1].ChangeID)
+
+	assert.Nil(t, doc.ApplyChange(replace(6, 60)))
+	assert.Len(t, doc.History(), 5)
+	assert.Nil(t, doc.ApplyChange(replace(7, 70)))
+	assert.Equal(t, `{"count":7,"late":true}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 3)
+	assert.Equal(t, "change5", doc.History()[0].ChangeID)
+	assert.Equal(t, []Operation{
+		{Op: "add", Path: "/count", Value: rawMessage("5")},
+		{Op: "add", Path: "/late", Value: rawMessage("true")},
+	}, doc.History()[0].Diff)
+}
+
+func TestMaxHistoryAge(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(100_000)
+	doc, err := NewDocument([]byte(`{"count":0}`), WithMaxHistoryAge(time.Minute), WithClock(func() time.Time {
+		return now
+	}))
+	assert.Nil(t, err)
+
+	for i, age := range []time.Duration{3 * time.Minute, 2 * time.Minute, 30 * time.Second, 10 * time.Second} {
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff: []Operation{
+				{
+					Op:    "replace",
+					Path:  "/count",
+					Value: rawMessage(fmt.Sprintf("%d", i+1)),
+				},
+			},
+			TimestampMillis: now.Add(-age).UnixMilli(),
+			ClientID:        "client1",
+			ChangeID:        fmt.Sprintf("change%d", i+1),
+		}))
+	}
+
+	assert.Equal(t, `{"count":4}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 3)
+	assert.Equal(t, "change2", doc.History()[0].ChangeID)
+
+	// time goes by
+	now = now.Add(time.Minute)
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{},
+		TimestampMillis: now.UnixMilli(),
+		ClientID:        "client1",
+		ChangeID:        "change5",
+	}))
+	assert.Equal(t, `{"count":4}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 2)
+	assert.Equal(t, "change4", doc.History()[0].ChangeID)
+}
@@ -0,0 +1,190 @@
+package pigeongo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrClockDrift = errors.New("timestamp too far in the future")
+
+// DriftPolicy decides what happens to remote timestamps too far in the future.
+type DriftPolicy int
+
+const (
+	// DriftAccept accepts all timestamps. The clock advances to the remote timestamp.
+	DriftAccept DriftPolicy = iota
+	// DriftReject rejects the change with ErrClockDrift.
+	DriftReject
+	// DriftClamp sets the timestamp to the maximum allowed timestamp.
+	DriftClamp
+)
+
+// HLC is a hybrid logical clock. It follows the wall clock, but never goes
+// backwards and always ticks after the newest timestamp it has seen. Timestamps
+// stay milliseconds, the logical counter is folded into the milliseconds, so
+// they are compatible with TimestampMillis of PigeonJS. A HLC is safe for
+// concurrent use and can be shared by many documents.
+type HLC struct {
+	mu       sync.Mutex
+	clock    func() time.Time
+	last     int64
+	maxDrift time.Duration
+	policy   DriftPolicy
+}
+
+type HLCOption func(*HLC)
+
+// WithHLCClock sets the wall clock of the HLC. The default is time.Now.
+func WithHLCClock(clock func() time.Time) HLCOption {
+	return func(c *HLC) {
+		c.clock = clock
+	}
+}
+
+// WithMaxDrift sets how far remote timestamps may be ahead of the wall clock
+// and what happens to timestamps further ahead.
+func WithMaxDrift(maxDrift time.Duration, policy DriftPolicy) HLCOption {
+	return func(c *HLC) {
+		c.maxDrift = maxDrift
+		c.policy = policy
+	}
+}
+
+// NewHLC creates a hybrid logical clock.
+func NewHLC(opts ...HLCOption) *HLC {
+	c := &HLC{
+		clock: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Now returns a new timestamp, that is newer than all timestamps returned or
+// received before.
+func (c *HLC) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock().UnixMilli()
+	if now <= c.last {
+		now = c.last + 1
+	}
+	c.last = now
+
+	return now
+}
+
+// Update advances the clock to a remote timestamp. It returns the timestamp
+// after the drift policy is applied.
+func (c *HLC) Update(remoteMillis int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timestamp, err := c.adjust(remoteMillis)
+	if err != nil {
+		return 0, err
+	}
+	c.observe(timestamp)
+
+	return timestamp, nil
+}
+
+// adjust applies the drift policy to a remote timestamp.
+func (c *HLC) adjust(remoteMillis int64) (int64, error) {
+	if c.maxDrift <= 0 || c.policy == DriftAccept {
+		return remoteMillis, nil
+	}
+
+	maxMillis := c.clock().Add(c.maxDrift).UnixMilli()
+	if remoteMillis <= maxMillis {
+		return remoteMillis, nil
+	}
+
+	if c.policy == DriftClamp {
+		return maxMillis, nil
+	}
+
+	return 0, fmt.Errorf("%w: %d is more than %s after %d", ErrClockDrift, remoteMillis, c.maxDrift, maxMillis-c.maxDrift.Milliseconds())
+}
+
+func (c *HLC) observe(timestamp int64) {
+	if timestamp > c.last {
+		c.last = timestamp
+	}
+}
+
+// WithHLC uses a hybrid logical clock for the timestamps of authored changes.
+// ApplyChange advances the clock with the timestamps of remote changes and
+// applies the drift policy of the clock to them.
+func WithHLC(clock *HLC) DocumentOption {
+	return func(d *Document) {
+		d.hlc = clock
+	}
+}
+
+// receiveChanges applies the drift policy to the timestamps of unknown changes.
+func (d *Document) receiveChanges(changes []Change) ([]Change, error) {
+	if d.hlc == nil {
+		return changes, nil
+	}
+
+	d.hlc.mu.Lock()
+	defer d.hlc.mu.Unlock()
+
+	received := make([]Change, len(changes))
+	for i, change := range changes {
+		if _, ok := d.changeIDs[change.ChangeID]; !ok {
+			timestamp, err := d.hlc.adjust(change.TimestampMillis)
+			if err != nil {
+				return nil, fmt.Errorf("clock error for changeID %s: %w", change.ChangeID, err)
+			}
+			change.TimestampMillis = timestamp
+		}
+		received[i] = change
+	}
+
+	return received, nil
+}
+
+// retimedChanges returns the known changes, that arrive with another timestamp
+// than the own copy in the history, like a change clamped by a relaying server.
+// They replace the own copy, so the author orders them like all other replicas.
+func (d *Document) retimedChanges(changes []Change) []Change {
+	retimed := []Change{}
+	seen := map[string]bool{}
+	for _, change := range changes {
+		if _, ok := d.changeIDs[change.ChangeID]; !ok || seen[change.ChangeID] {
+			continue
+		}
+
+		for _, own := range d.history[1:] {
+			if own.ChangeID == change.ChangeID && own.TimestampMillis != change.TimestampMillis {
+				seen[change.ChangeID] = true
+				retimed = append(retimed, change)
+				break
+			}
+		}
+	}
+
+	return retimed
+}
+
+// observeChanges advances the clock to the timestamps of received changes.
+func (d *Document) observeChanges(changes []Change) {
+	if d.hlc == nil {
+		return
+	}
+
+	d.hlc.mu.Lock()
+	defer d.hlc.mu.Unlock()
+
+	for _, change := range changes {
+		d.hlc.observe(change.TimestampMillis)
+	}
+}
@@ -0,0 +1,205 @@
+package pigeongo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHLC(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1000)
+	clock := NewHLC(WithHLCClock(func() time.Time {
+		return now
+	}))
+
+	assert.Equal(t, int64(1000), clock.Now())
+	// the wall clock stands still or goes backwards
+	assert.Equal(t, int64(1001), clock.Now())
+	now = time.UnixMilli(900)
+	assert.Equal(t, int64(1002), clock.Now())
+
+	// remote timestamps advance the clock
+	timestamp, err := clock.Update(5000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5000), timestamp)
+	assert.Equal(t, int64(5001), clock.Now())
+
+	// older remote timestamps don't
+	timestamp, err = clock.Update(10)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), timestamp)
+	assert.Equal(t, int64(5002), clock.Now())
+
+	now = time.UnixMilli(6000)
+	assert.Equal(t, int64(6000), clock.Now())
+}
+
+func TestHLCDrift(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(10_000)
+	wall := WithHLCClock(func() time.Time {
+		return now
+	})
+
+	reject := NewHLC(wall, WithMaxDrift(time.Second, DriftReject))
+	timestamp, err := reject.Update(11_000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11_000), timestamp)
+	_, err = reject.Update(11_001)
+	assert.True(t, errors.Is(err, ErrClockDrift))
+	assert.EqualError(t, err, "timestamp too far in the future: 11001 is more than 1s after 10000")
+	assert.Equal(t, int64(11_001), reject.Now())
+
+	clamp := NewHLC(wall, WithMaxDrift(time.Second, DriftClamp))
+	timestamp, err = clamp.Update(50_000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11_000), timestamp)
+
+	accept := NewHLC(wall, WithMaxDrift(time.Second, DriftAccept))
+	timestamp, err = accept.Update(50_000)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50_000), timestamp)
+}
+
+func TestDocumentHLC(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(10_000)
+	clock := NewHLC(WithHLCClock(func() time.Time {
+		return now
+	}), WithMaxDrift(time.Minute, DriftReject))
+
+	doc, err := NewDocument([]byte(`{"count":0}`), WithHLC(clock))
+	assert.Nil(t, err)
+
+	// a remote change from a clock ahead
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("1")}},
+		TimestampMillis: 20_000,
+		ClientID:        "remote",
+		ChangeID:        "remote1",
+	}))
+
+	// the local change is ordered after the remote change
+	change, err := doc.Commit("local", []byte(`{"count":2}`))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20_001), change.TimestampMillis)
+	assert.Equal(t, "local", doc.History()[2].ClientID)
+
+	// too far in the future, nothing changes
+	err = doc.ApplyChanges([]Change{
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("3")}},
+			TimestampMillis: 20_002,
+			ClientID:        "remote",
+			ChangeID:        "remote2",
+		},
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("4")}},
+			TimestampMillis: 100_000,
+			ClientID:        "remote",
+			ChangeID:        "remote3",
+		},
+	})
+	assert.True(t, errors.Is(err, ErrClockDrift))
+	assert.Equal(t, `{"count":2}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 3)
+	assert.Equal(t, int64(20_002), clock.Now())
+
+	// known changes are skipped before the policy
+	assert.Nil(t, doc.ApplyChange(change))
+}
+
+func TestDocumentHLCClamp(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(10_000)
+	clock := NewHLC(WithHLCClock(func() time.Time {
+		return now
+	}), WithMaxDrift(time.Second, DriftClamp))
+
+	server, err := NewDocument([]byte(`{"count":0}`), WithHLC(clock))
+	assert.Nil(t, err)
+	peer, err := NewDocument([]byte(`{"count":0}`))
+	assert.Nil(t, err)
+
+	remote := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("1")}},
+		TimestampMillis: 50_000,
+		ClientID:        "remote",
+		ChangeID:        "remote1",
+	}
+	local := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("2")}},
+		TimestampMillis: 20_000,
+		ClientID:        "local",
+		ChangeID:        "local1",
+	}
+
+	// the server relays the clamped change
+	relayed, err := server.ReceiveChanges([]Change{remote})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11_000), relayed[0].TimestampMillis)
+	assert.Equal(t, int64(11_000), server.History()[1].TimestampMillis)
+	assert.Equal(t, int64(50_000), remote.TimestampMillis)
+
+	// peers order the relayed change before the newer local change like the server
+	now = time.UnixMilli(30_000)
+	assert.Nil(t, server.ApplyChange(local))
+	assert.Nil(t, peer.ApplyChanges(relayed))
+	assert.Nil(t, peer.ApplyChange(local))
+	assert.Equal(t, `{"count":2}`, string(server.JSON()))
+	assert.Equal(t, string(server.JSON()), string(peer.JSON()))
+	assert.Equal(t, server.History(), peer.History())
+}
+
+func TestDocumentHLCClampAuthor(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(10_000)
+	clock := NewHLC(WithHLCClock(func() time.Time {
+		return now
+	}), WithMaxDrift(time.Second, DriftClamp))
+
+	server, err := NewDocument([]byte(`{"count":0}`), WithHLC(clock))
+	assert.Nil(t, err)
+	author, err := NewDocument([]byte(`{"count":0}`))
+	assert.Nil(t, err)
+
+	own := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("1")}},
+		TimestampMillis: 50_000,
+		ClientID:        "author",
+		ChangeID:        "author1",
+	}
+	other := Change{
+		Diff:            []Operation{{Op: "replace", Path: "/count", Value: rawMessage("2")}},
+		TimestampMillis: 20_000,
+		ClientID:        "other",
+		ChangeID:        "other1",
+	}
+
+	assert.Nil(t, author.ApplyChange(own))
+	relayed, err := server.ReceiveChanges([]Change{own})
+	assert.Nil(t, err)
+	now = time.UnixMilli(30_000)
+	others, err := server.ReceiveChanges([]Change{other})
+	assert.Nil(t, err)
+	relayed = append(relayed, others...)
+
+	// the author replaces the own change by the clamped copy
+	assert.Nil(t, author.ApplyChanges(relayed))
+	assert.Equal(t, `{"count":2}`, string(server.JSON()))
+	assert.Equal(t, string(server.JSON()), string(author.JSON()))
+	assert.Equal(t, server.History(), author.History())
+	assert.Empty(t, author.Warnings())
+
+	// relaying the changes again changes nothing
+	assert.Nil(t, author.ApplyChanges(relayed))
+	assert.Equal(t, server.History(), author.History())
+}
@@ -0,0 +1,136 @@
+package pigeongo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeError contains all changes that could not be replayed by Merge.
+type MergeError struct {
+	Changes []Change
+	Errors  []error
+}
+
+func (e *MergeError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("merge error: %d changes failed: %s", len(e.Changes), strings.Join(messages, "; "))
+}
+
+func (e *MergeError) Unwrap() []error {
+	return e.Errors
+}
+
+// Merge two replicas of a document like PigeonJS `Pigeon.merge`. The histories
+// must share an initial change. The changes of the replica with the newer initial
+// change are replayed on a clone of the other one, known changes are skipped.
+// Changes folded by ReduceHistory can't be replayed, so the merge fails, if the
+// other replica folded changes the base doesn't know. Replicas with the same
+// initial changeID must start with the same content. If changes fail, the
+// merged document is returned together with a *MergeError.
+func Merge(left, right *Document) (*Document, error) {
+	base, other, err := mergeBase(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := base.Clone()
+
+	var mergeErr *MergeError
+	// operations are updated in place during fast forward, the replayed changes
+	// must not share them with the other replica
+	for _, change := range copyChanges(other.history[1:]) {
+		if err := merged.ApplyChange(change); err != nil {
+			if mergeErr == nil {
+				mergeErr = &MergeError{}
+			}
+			mergeErr.Changes = append(mergeErr.Changes, change)
+			mergeErr.Errors = append(mergeErr.Errors, err)
+		}
+	}
+
+	if mergeErr != nil {
+		return merged, mergeErr
+	}
+
+	return merged, nil
+}
+
+// mergeBase returns the document with the oldest initial change as base. Changes
+// folded into the initial change of the other document must be known to the base.
+func mergeBase(left, right *Document) (*Document, *Document, error) {
+	base, other := left, right
+
+	var err error
+	switch {
+	case left.history[0].ChangeID == right.history[0].ChangeID:
+		// the default initial changeID doesn't tell, if the documents are related
+		err = sameInitialContent(left, right)
+	case containsChange(left, right.history[0].ChangeID):
+		// right is reduced to a change of left
+	case containsChange(right, left.history[0].ChangeID):
+		base, other = right, left
+	default:
+		err = errors.New("merge error: documents have no common initial change")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if folded := foldedChanges(base, other); len(folded) > 0 {
+		return nil, nil, fmt.Errorf("merge error: changes %s are folded into the initial change %s", strings.Join(folded, ", "), other.history[0].ChangeID)
+	}
+
+	return base, other, nil
+}
+
+func containsChange(d *Document, changeID string) bool {
+	for _, change := range d.history[1:] {
+		if change.ChangeID == changeID {
+			return true
+		}
+	}
+	return false
+}
+
+// sameInitialContent compares the documents at their initial changes.
+func sameInitialContent(left, right *Document) error {
+	checksums := [2]int64{}
+	for i, d := range []*Document{left, right} {
+		initial := d.Clone()
+		if err := initial.rewindWhile(func(Change) bool { return true }); err != nil {
+			return fmt.Errorf("merge error: %s", err.Error())
+		}
+		checksums[i] = initial.Checksum()
+	}
+
+	if checksums[0] != checksums[1] {
+		return fmt.Errorf("merge error: documents differ at the initial change %s", left.history[0].ChangeID)
+	}
+
+	return nil
+}
+
+// foldedChanges returns the changeIDs, that are folded into the initial change
+// of other and unknown to base.
+func foldedChanges(base, other *Document) []string {
+	inHistory := map[string]bool{}
+	for _, change := range other.history {
+		inHistory[change.ChangeID] = true
+	}
+
+	folded := []string{}
+	for changeID := range other.changeIDs {
+		if _, ok := base.changeIDs[changeID]; !ok && !inHistory[changeID] {
+			folded = append(folded, changeID)
+		}
+	}
+	sort.Strings(folded)
+
+	return folded
+}
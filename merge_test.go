@@ -0,0 +1,211 @@
+package pigeongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+
+	origin, err := NewDocument([]byte(`{"title":"Board","cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+
+	left := origin.Clone()
+	right := origin.Clone()
+
+	shared := Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/title",
+				Value: rawMessage(`"Shared"`),
+			},
+		},
+		TimestampMillis: 1,
+		ClientID:        "client1",
+		ChangeID:        "shared",
+	}
+	assert.Nil(t, left.ApplyChange(shared))
+	assert.Nil(t, right.ApplyChange(shared))
+
+	assert.Nil(t, left.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/cards/1",
+				Value: rawMessage(`{"id":"card2","text":"baa"}`),
+			},
+		},
+		TimestampMillis: 3,
+		ClientID:        "client1",
+		ChangeID:        "left",
+	}))
+	assert.Nil(t, right.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/cards/[card1]/text",
+				Value: rawMessage(`"bar"`),
+			},
+		},
+		TimestampMillis: 2,
+		ClientID:        "client2",
+		ChangeID:        "right",
+	}))
+
+	merged, err := Merge(left, right)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"bar"},{"id":"card2","text":"baa"}],"title":"Shared"}`, string(merged.JSON()))
+
+	ids := []string{}
+	for _, change := range merged.History() {
+		ids = append(ids, change.ChangeID)
+	}
+	assert.Equal(t, []string{"0", "shared", "right", "left"}, ids)
+
+	// merge is symmetric
+	merged, err = Merge(right, left)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"bar"},{"id":"card2","text":"baa"}],"title":"Shared"}`, string(merged.JSON()))
+
+	// the replicas are untouched
+	assert.Len(t, left.History(), 3)
+	assert.Len(t, right.History(), 3)
+
+	// reduced replica
+	reduced := left.Clone()
+	assert.Nil(t, reduced.ReduceHistory(2))
+	merged, err = Merge(reduced, right)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"bar"},{"id":"card2","text":"baa"}],"title":"Shared"}`, string(merged.JSON()))
+}
+
+func TestMergeFailedChanges(t *testing.T) {
+	t.Parallel()
+
+	origin, err := NewDocument([]byte(`{"cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+
+	left := origin.Clone()
+	right := origin.Clone()
+
+	assert.Nil(t, left.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:   "remove",
+				Path: "/cards/[card1]",
+			},
+		},
+		TimestampMillis: 1,
+		ClientID:        "client1",
+		ChangeID:        "remove",
+	}))
+	assert.Nil(t, right.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/cards/[card1]/text",
+				Value: rawMessage(`"bar"`),
+			},
+		},
+		TimestampMillis: 2,
+		ClientID:        "client2",
+		ChangeID:        "replace",
+	}))
+
+	merged, err := Merge(left, right)
+	assert.NotNil(t, merged)
+	assert.Equal(t, `{"cards":[]}`, string(merged.JSON()))
+
+	var mergeErr *MergeError
+	assert.True(t, errors.As(err, &mergeErr))
+	assert.Len(t, mergeErr.Changes, 1)
+	assert.Equal(t, "replace", mergeErr.Changes[0].ChangeID)
+	assert.Equal(t, "merge error: 1 changes failed: patch error: can't apply changeID replace: id `card1` not found", err.Error())
+
+	// no common history
+	other, err := NewDocument([]byte(`{}`), WithInitialIDs("other", "other"))
+	assert.Nil(t, err)
+	_, err = Merge(left, other)
+	assert.Equal(t, "merge error: documents have no common initial change", err.Error())
+
+	// unrelated documents with the default initial change
+	other, err = NewDocument([]byte(`{"cards":[]}`))
+	assert.Nil(t, err)
+	_, err = Merge(left, other)
+	assert.Equal(t, "merge error: documents differ at the initial change 0", err.Error())
+}
+
+func TestMergeFoldedChanges(t *testing.T) {
+	t.Parallel()
+
+	origin, err := NewDocument([]byte(`{"count":0}`))
+	assert.Nil(t, err)
+
+	left := origin.Clone()
+	right := origin.Clone()
+
+	change := func(changeID string, timestampMillis int64) Change {
+		return Change{
+			Diff:            []Operation{{Op: "add", Path: "/" + changeID, Value: rawMessage("true")}},
+			TimestampMillis: timestampMillis,
+			ClientID:        "client1",
+			ChangeID:        changeID,
+		}
+	}
+
+	assert.Nil(t, left.ApplyChange(change("shared", 10)))
+	assert.Nil(t, right.ApplyChange(change("shared", 10)))
+	assert.Nil(t, right.ApplyChange(change("offline", 5)))
+	assert.Nil(t, right.ApplyChange(change("right", 30)))
+	assert.Nil(t, right.ReduceHistory(20))
+	assert.Equal(t, "shared", right.History()[0].ChangeID)
+
+	// the offline change exists only in the initial diff of right
+	_, err = Merge(left, right)
+	assert.Equal(t, "merge error: changes offline are folded into the initial change shared", err.Error())
+
+	// known folded changes are merged
+	assert.Nil(t, left.ApplyChange(change("offline", 5)))
+	merged, err := Merge(right, left)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"count":0,"shared":true,"offline":true,"right":true}`, string(merged.JSON()))
+}
+
+func TestMergeKeepsReplicas(t *testing.T) {
+	t.Parallel()
+
+	origin, err := NewDocument([]byte(`{"title":"Board"}`))
+	assert.Nil(t, err)
+
+	left := origin.Clone()
+	right := origin.Clone()
+
+	title := func(changeID string, timestampMillis int64) Change {
+		return Change{
+			Diff:            []Operation{{Op: "replace", Path: "/title", Value: rawMessage(`"` + changeID + `"`)}},
+			TimestampMillis: timestampMillis,
+			ClientID:        changeID,
+			ChangeID:        changeID,
+		}
+	}
+	assert.Nil(t, left.ApplyChange(title("left", 1)))
+	assert.Nil(t, right.ApplyChange(title("right", 2)))
+
+	merged, err := Merge(left, right)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"title":"right"}`, string(merged.JSON()))
+	assert.Equal(t, `"left"`, string(*merged.History()[2].Diff[0].Prev))
+
+	// the replayed changes don't share operations with the replicas
+	assert.Equal(t, `"Board"`, string(*right.History()[1].Diff[0].Prev))
+	assert.Equal(t, `"Board"`, string(*left.History()[1].Diff[0].Prev))
+	before, err := right.At(0)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"title":"Board"}`, string(before.JSON()))
+	assert.Equal(t, `{"title":"right"}`, string(right.JSON()))
+	assert.Equal(t, `{"title":"left"}`, string(left.JSON()))
+}
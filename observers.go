@@ -0,0 +1,95 @@
+package pigeongo
+
+// Event types.
+const (
+	// EventApplied is emitted for a new change.
+	EventApplied = "applied"
+	// EventRewound is emitted for a change, that is reversed to apply an older change.
+	EventRewound = "rewound"
+	// EventFastForwarded is emitted for a rewound change, that is applied again.
+	EventFastForwarded = "forwarded"
+	// EventReduced is emitted if the history is folded into a new initial diff.
+	EventReduced = "reduced"
+)
+
+// Event describes a mutation of the document.
+type Event struct {
+	Type   string
+	Change Change
+	// Operations are the applied operations with resolved index paths like
+	// `/cards/2/name`. For EventRewound these are the reversed operations and
+	// for EventReduced the new initial diff.
+	Operations []Operation
+}
+
+// Observer is called after the document changed.
+type Observer func(event Event)
+
+type observerEntry struct {
+	id       int
+	observer Observer
+}
+
+// Observe registers an observer, that is called synchronously with all events of
+// a successful mutation. Failed mutations emit no events. The returned function
+// removes the observer.
+func (d *Document) Observe(observer Observer) func() {
+	d.observerID++
+	id := d.observerID
+	d.observers = append(d.observers, observerEntry{id: id, observer: observer})
+
+	return func() {
+		for i, entry := range d.observers {
+			if entry.id == id {
+				d.observers = append(d.observers[:i:i], d.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// workingCopy clones the document and records events, if observers are registered.
+func (d *Document) workingCopy() *Document {
+	workingCopy := d.Clone()
+	workingCopy.recordEvents = len(d.observers) > 0
+	return workingCopy
+}
+
+func (d *Document) emit(eventType string, change Change, operations []Operation) {
+	if !d.recordEvents {
+		return
+	}
+
+	d.events = append(d.events, Event{
+		Type:       eventType,
+		Change:     change,
+		Operations: operations,
+	})
+}
+
+// emitReduced replaces the events since mark, that are recorded while folding
+// the history, by a single EventReduced.
+func (d *Document) emitReduced(mark int) {
+	if !d.recordEvents {
+		return
+	}
+
+	d.events = d.events[:mark]
+	d.emit(EventReduced, d.history[0], d.history[0].Diff)
+}
+
+// notify calls all observers with the recorded events of the working copy.
+func (d *Document) notify(events []Event) {
+	if len(events) == 0 || len(d.observers) == 0 {
+		return
+	}
+
+	observers := make([]observerEntry, len(d.observers))
+	copy(observers, d.observers)
+
+	for _, event := range events {
+		for _, entry := range observers {
+			entry.observer(event)
+		}
+	}
+}
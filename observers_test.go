@@ -0,0 +1,122 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserve(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp","cards":[{"id":"card1","text":"foo"},{"id":"card2","text":"bar"}]}`))
+	assert.Nil(t, err)
+
+	events := []Event{}
+	remove := doc.Observe(func(event Event) {
+		events = append(events, event)
+	})
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/cards/[card2]/text",
+				Value: rawMessage(`"baz"`),
+			},
+		},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventApplied, events[0].Type)
+	assert.Equal(t, "change1", events[0].Change.ChangeID)
+	assert.Equal(t, []Operation{
+		{
+			Op:    "replace",
+			Path:  "/cards/1/text",
+			Value: rawMessage(`"baz"`),
+			Prev:  rawMessage(`"bar"`),
+		},
+	}, events[0].Operations)
+
+	// out of order change
+	events = []Event{}
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:   "remove",
+				Path: "/cards/[card1]",
+			},
+		},
+		TimestampMillis: 5,
+		ClientID:        "client2",
+		ChangeID:        "change2",
+	}))
+	assert.Len(t, events, 3)
+	assert.Equal(t, EventRewound, events[0].Type)
+	assert.Equal(t, "change1", events[0].Change.ChangeID)
+	assert.Equal(t, "/cards/1/text", events[0].Operations[0].Path)
+	assert.Equal(t, EventApplied, events[1].Type)
+	assert.Equal(t, "change2", events[1].Change.ChangeID)
+	assert.Equal(t, "/cards/0", events[1].Operations[0].Path)
+	assert.Equal(t, EventFastForwarded, events[2].Type)
+	assert.Equal(t, "change1", events[2].Change.ChangeID)
+	assert.Equal(t, "/cards/0/text", events[2].Operations[0].Path)
+
+	// failed changes emit nothing
+	events = []Event{}
+	assert.NotNil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:   "remove",
+				Path: "/cards/[card1]",
+			},
+		},
+		TimestampMillis: 1,
+		ClientID:        "client2",
+		ChangeID:        "change3",
+	}))
+	assert.Empty(t, events)
+
+	// reduce emits only the new initial diff
+	assert.Nil(t, doc.ReduceHistory(7))
+	assert.Len(t, events, 1)
+	assert.Equal(t, EventReduced, events[0].Type)
+	assert.Equal(t, "change2", events[0].Change.ChangeID)
+	assert.Equal(t, doc.History()[0].Diff, events[0].Operations)
+
+	// clones have no observers
+	events = []Event{}
+	clone := doc.Clone()
+	_, err = clone.Commit("client1", []byte(`{}`))
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	// removed observer
+	remove()
+	remove()
+	_, err = doc.Commit("client1", []byte(`{}`))
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+}
+
+func TestObserveHistoryLimit(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"count":0}`), WithMaxHistory(1))
+	assert.Nil(t, err)
+
+	types := []string{}
+	doc.Observe(func(event Event) {
+		types = append(types, event.Type)
+	})
+
+	_, err = doc.Commit("client1", []byte(`{"count":1}`))
+	assert.Nil(t, err)
+	_, err = doc.Commit("client1", []byte(`{"count":2}`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{EventApplied, EventApplied, EventReduced}, types)
+}
@@ -1,157 +1,622 @@
 package pigeongo
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
-	"github.com/buger/jsonparser"
 	jsonpatch "gopkg.in/evanphx/json-patch.v5"
 )
 
+var (
+	errMissingPath = fmt.Errorf("operation missing path field: %w", jsonpatch.ErrMissing)
+	errMissingFrom = fmt.Errorf("operation, missing from field: %w", jsonpatch.ErrMissing)
+)
+
 func patch(doc []byte, operations []Operation, identifiers [][]string) ([]byte, error) {
-	newDoc := doc
-	var err error
+	root, _, err := patchTree(parseRoot(doc), operations, identifiers)
+	if err != nil {
+		return doc, err
+	}
+
+	if len(operations) == 0 || root.kind == kindInvalid {
+		return doc, nil
+	}
+
+	return root.JSON(), nil
+}
+
+// patch applies the operations to the document and returns the operations with
+// resolved index paths. The document is unchanged on errors.
+func (d *Document) patch(operations []Operation) ([]Operation, error) {
+	root, resolved, err := patchTree(d.root, operations, d.identifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	d.root = root
+	if len(operations) > 0 && root.kind != kindInvalid {
+		// json-patch serializes the document after every patch
+		d.raw = nil
+	}
+
+	return resolved, nil
+}
+
+// patchTree applies the operations one by one and returns the new root and the
+// operations with resolved index paths. The root is unchanged on errors.
+func patchTree(root *node, operations []Operation, identifiers [][]string) (*node, []Operation, error) {
+	resolved := make([]Operation, 0, len(operations))
 
 	for _, operation := range operations {
-		patchObj := NewJsonpatchPatch([]Operation{operation})
-		patchObj, err = replacePaths(newDoc, patchObj, identifiers)
+		newRoot, resolvedOperation, err := patchOperation(root, operation, identifiers)
 		if err != nil {
-			return doc, err
+			return nil, nil, err
 		}
 
-		patchObj = fixEndOfArrayPaths(newDoc, patchObj)
+		root = newRoot
+		resolved = append(resolved, resolvedOperation)
+	}
+
+	return root, resolved, nil
+}
+
+// patchOperation applies a single operation with the semantics of json-patch.
+func patchOperation(root *node, operation Operation, identifiers [][]string) (*node, Operation, error) {
+	// json-patch reads a missing path or from as `unknown`, if the other one is set
+	path, from := operation.Path, operation.From
+	missing := path == "" && from == ""
+	if path == "" {
+		path = "unknown"
+	}
+	if from == "" {
+		from = "unknown"
+	}
+
+	if !missing {
+		var err error
+		if from, err = replacePath(root, from, identifiers); err != nil {
+			return nil, operation, err
+		}
+		from = fixEndOfArrayPath(root, from)
 
-		newDoc, err = patchObj.Apply(newDoc)
+		// the target of a move is resolved without the moved value, so `/items/[id]`
+		// inserts before the item like PigeonJS
+		target := root
+		if operation.Op == "move" {
+			if removed, err := patchRemove(root, from, false, identifiers); err == nil {
+				target = removed
+			}
+		}
+
+		resolved, err := replacePath(target, path, identifiers)
+		if err != nil && target != root {
+			// an item moved to itself is resolved like from
+			if self, selfErr := replacePath(root, path, identifiers); selfErr == nil && self == from {
+				resolved, target, err = self, root, nil
+			}
+		}
 		if err != nil {
-			return doc, err
+			return nil, operation, err
+		}
+		path = fixEndOfArrayPath(target, resolved)
+
+		if operation.Path != "" {
+			operation.Path = path
+		}
+		if operation.From != "" {
+			operation.From = from
 		}
 	}
 
-	return newDoc, nil
+	if root.kind == kindInvalid {
+		if len(root.raw) == 0 {
+			// json-patch returns empty documents unchanged
+			return root, operation, nil
+		}
+
+		_, err := jsonpatch.Patch{}.Apply(root.raw)
+		return nil, operation, err
+	}
+
+	var newRoot *node
+	var err error
+	switch operation.Op {
+	case "add":
+		newRoot, err = patchAdd(root, path, operation.Value, missing, identifiers)
+	case "remove":
+		newRoot, err = patchRemove(root, path, missing, identifiers)
+	case "replace":
+		newRoot, err = patchReplace(root, path, operation.Value, missing, identifiers)
+	case "move":
+		newRoot, err = patchMove(root, from, path, missing, identifiers)
+	case "copy":
+		newRoot, err = patchCopy(root, from, path, missing, identifiers)
+	case "test":
+		newRoot, err = patchTest(root, path, operation.Value, missing, identifiers)
+	default:
+		err = fmt.Errorf("unexpected kind: %s", operation.Op)
+	}
+	if err != nil {
+		return nil, operation, err
+	}
+
+	return newRoot, operation, nil
 }
 
-func replacePaths(doc []byte, patchObj jsonpatch.Patch, identifiers [][]string) (jsonpatch.Patch, error) {
-	for _, patch := range patchObj {
-		path, errPath := patch.Path()
-		from, errFrom := patch.From()
-		if errPath != nil && errFrom != nil {
-			continue
+func patchAdd(root *node, path string, value *json.RawMessage, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("add operation failed to decode path: %w", jsonpatch.ErrMissing)
+	}
+
+	location, ok := findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("add operation does not apply: doc is missing path: \"%s\": %w", path, jsonpatch.ErrMissing)
+	}
+
+	child, err := valueNode(value)
+	if err == nil {
+		var container *node
+		if container, err = containerAdd(location.container, location.key, child); err != nil {
+			return nil, fmt.Errorf("error in add for path: '%s': %w", path, err)
 		}
+		return location.rebuild(container, identifiers), nil
+	}
+
+	if _, addErr := containerAdd(location.container, location.key, nullNode); addErr != nil {
+		return nil, fmt.Errorf("error in add for path: '%s': %w", path, addErr)
+	}
+	return nil, fmt.Errorf("error in add for path: '%s': invalid value: %w", path, err)
+}
+
+func patchRemove(root *node, path string, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("remove operation failed to decode path: %w", jsonpatch.ErrMissing)
+	}
+
+	location, ok := findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("remove operation does not apply: doc is missing path: \"%s\": %w", path, jsonpatch.ErrMissing)
+	}
+
+	container, err := containerRemove(location.container, location.key)
+	if err != nil {
+		return nil, fmt.Errorf("error in remove for path: '%s': %w", path, err)
+	}
+
+	return location.rebuild(container, identifiers), nil
+}
+
+func patchReplace(root *node, path string, value *json.RawMessage, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("replace operation failed to decode path: %w", errMissingPath)
+	}
+
+	location, ok := findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("replace operation does not apply: doc is missing path: %s: %w", path, jsonpatch.ErrMissing)
+	}
+
+	if _, err := containerGet(location.container, location.key); err != nil {
+		return nil, fmt.Errorf("replace operation does not apply: doc is missing key: %s: %w", path, jsonpatch.ErrMissing)
+	}
+
+	child, err := valueNode(value)
+	if err != nil {
+		return nil, fmt.Errorf("error in replace for path: '%s': invalid value: %w", path, err)
+	}
+
+	container, err := containerSet(location.container, location.key, child, identifiers)
+	if err != nil {
+		return nil, fmt.Errorf("error in remove for path: '%s': %w", path, err)
+	}
+
+	return location.rebuild(container, identifiers), nil
+}
+
+func patchMove(root *node, from, path string, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("move operation failed to decode from: %w", errMissingFrom)
+	}
+
+	location, ok := findContainer(root, from)
+	if !ok {
+		return nil, fmt.Errorf("move operation does not apply: doc is missing from path: %s: %w", from, jsonpatch.ErrMissing)
+	}
+
+	child, err := containerGet(location.container, location.key)
+	if err != nil {
+		return nil, fmt.Errorf("error in move for path: '%s': %w", location.key, err)
+	}
+
+	container, err := containerRemove(location.container, location.key)
+	if err != nil {
+		return nil, fmt.Errorf("error in move for path: '%s': %w", location.key, err)
+	}
+	root = location.rebuild(container, identifiers)
+
+	location, ok = findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("move operation does not apply: doc is missing destination path: %s: %w", path, jsonpatch.ErrMissing)
+	}
+
+	if container, err = containerAdd(location.container, location.key, child); err != nil {
+		return nil, fmt.Errorf("error in move for path: '%s': %w", path, err)
+	}
+
+	return location.rebuild(container, identifiers), nil
+}
+
+func patchCopy(root *node, from, path string, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("copy operation failed to decode from: %w", errMissingFrom)
+	}
+
+	location, ok := findContainer(root, from)
+	if !ok {
+		return nil, fmt.Errorf("copy operation does not apply: doc is missing from path: %s: %w", from, jsonpatch.ErrMissing)
+	}
+
+	if _, err := containerGet(location.container, location.key); err != nil {
+		return nil, fmt.Errorf("error in copy for from: '%s': %w", from, err)
+	}
+
+	// the containers of from are parsed, too
+	root = location.rebuild(location.container.built(), identifiers)
+
+	location, ok = findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("copy operation does not apply: doc is missing destination path: %s: %w", path, jsonpatch.ErrMissing)
+	}
+
+	// json-patch serializes the value after walking to path, so a value
+	// containing the containers of path is copied with their keys sorted
+	root = location.rebuild(location.container.built(), identifiers)
+	fromLocation, _ := findContainer(root, from)
+	child, _ := containerGet(fromLocation.container, fromLocation.key)
+	if child == nil {
+		child = nullNode
+	}
+
+	location, _ = findContainer(root, path)
+	container, err := containerAdd(location.container, location.key, child)
+	if err != nil {
+		return nil, fmt.Errorf("error while adding value during copy: %w", err)
+	}
+
+	return location.rebuild(container, identifiers), nil
+}
+
+func patchTest(root *node, path string, value *json.RawMessage, missing bool, identifiers [][]string) (*node, error) {
+	if missing {
+		return nil, fmt.Errorf("test operation failed to decode path: %w", errMissingPath)
+	}
 
-		if path != "" {
-			path, err := replacePath(doc, path, identifiers)
-			if err != nil {
-				return nil, err
+	location, ok := findContainer(root, path)
+	if !ok {
+		return nil, fmt.Errorf("test operation does not apply: is missing path: %s: %w", path, jsonpatch.ErrMissing)
+	}
+
+	child, err := containerGet(location.container, location.key)
+	if err != nil {
+		return nil, fmt.Errorf("error in test for path: '%s': %w", path, err)
+	}
+
+	expected, err := valueNode(value)
+	container := location.container.built()
+	if child.isNull() {
+		if err != nil || !expected.isNull() {
+			return nil, fmt.Errorf("testing value %s failed: %w", path, jsonpatch.ErrTestFailed)
+		}
+	} else {
+		if err != nil || expected.isNull() || !testEqual(child, expected) {
+			return nil, fmt.Errorf("testing value %s failed: %w", path, jsonpatch.ErrTestFailed)
+		}
+
+		// json-patch parses all compared containers
+		if container, err = containerSet(container, location.key, child.deepBuilt(), identifiers); err != nil {
+			return nil, fmt.Errorf("error in test for path: '%s': %w", path, err)
+		}
+	}
+
+	return location.rebuild(container, identifiers), nil
+}
+
+// testEqual compares a document value with an expected value like json-patch.
+func testEqual(actual, expected *node) bool {
+	switch actual.kind {
+	case kindObject:
+		if expected.kind != kindObject && expected.kind != kindNull {
+			return false
+		}
+
+		actualFields, _ := actual.children()
+		expectedFields, _ := expected.children()
+		if len(actualFields) != len(expectedFields) {
+			return false
+		}
+
+		for key, child := range actualFields {
+			expectedChild, ok := expectedFields[key]
+			if !ok || child.isNull() != expectedChild.isNull() {
+				return false
 			}
-			patch["path"] = rawMessage(fmt.Sprintf("\"%s\"", path))
+			if !child.isNull() && !testEqual(child, expectedChild) {
+				return false
+			}
+		}
+
+		return true
+	case kindArray:
+		if expected.kind != kindArray && expected.kind != kindNull {
+			return false
 		}
 
-		if from != "" {
-			from, err := replacePath(doc, from, identifiers)
-			if err != nil {
-				return nil, err
+		_, actualItems := actual.children()
+		_, expectedItems := expected.children()
+		if len(actualItems) != len(expectedItems) {
+			return false
+		}
+
+		for i, child := range actualItems {
+			if child.isNull() != expectedItems[i].isNull() {
+				return false
+			}
+			if !child.isNull() && !testEqual(child, expectedItems[i]) {
+				return false
 			}
-			patch["from"] = rawMessage(fmt.Sprintf("\"%s\"", from))
 		}
+
+		return true
+	default:
+		if expected.raw == nil || expected.isContainer() {
+			return false
+		}
+		if string(actual.raw) == string(expected.raw) {
+			return true
+		}
+		if actual.kind != kindString || expected.kind != kindString {
+			return false
+		}
+
+		// strings are compared decoded, so escaping doesn't matter
+		var actualString, expectedString string
+		if json.Unmarshal(actual.raw, &actualString) != nil || json.Unmarshal(expected.raw, &expectedString) != nil {
+			return false
+		}
+		return actualString == expectedString
 	}
+}
 
-	return patchObj, nil
+// location is the container of a path and all containers walked through to it.
+type location struct {
+	parents   []*node
+	positions []string
+	container *node
+	key       string
 }
 
-func replacePath(doc []byte, path string, identifiers [][]string) (string, error) {
+// findContainer walks to the container of the last path segment like json-patch.
+func findContainer(root *node, path string) (location, bool) {
 	parts := strings.Split(path, "/")
-	newParts := make([]string, len(parts))
-	keys := []string{}
-
-	for partIndex, part := range parts {
-		if part == "" {
-			newParts[partIndex] = part
-			continue
-		}
-
-		if strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]") {
-			searchID := strings.TrimSuffix(strings.TrimPrefix(part, "["), "]")
-			childPosition := 0
-			found := false
-			if _, err := jsonparser.ArrayEach(doc, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-				if err != nil {
-					panic(err)
-				}
-
-				if findID(value, identifiers) == searchID {
-					keys = append(keys, fmt.Sprintf("[%d]", childPosition))
-					newParts[partIndex] = fmt.Sprintf("%d", childPosition)
-					found = true
-				}
-
-				childPosition++
-			}, keys...); err != nil {
-				keys = append(keys, part)
-				newParts[partIndex] = part
-			}
+	if len(parts) < 2 {
+		return location{}, false
+	}
 
-			if !found {
-				return "", errors.New("id `" + searchID + "` not found")
-			}
+	current := root
+	result := location{}
+	for _, part := range parts[1 : len(parts)-1] {
+		key := decodePatchKey(part)
+
+		child, err := containerGet(current, key)
+		if err != nil || child.isNull() || !child.isContainer() {
+			return location{}, false
+		}
+
+		result.parents = append(result.parents, current)
+		result.positions = append(result.positions, key)
+		current = child
+	}
+
+	result.container = current
+	result.key = decodePatchKey(parts[len(parts)-1])
+	return result, true
+}
+
+// rebuild copies all parents with the changed container.
+func (l location) rebuild(container *node, identifiers [][]string) *node {
+	for i := len(l.parents) - 1; i >= 0; i-- {
+		parent := l.parents[i].built()
+
+		if parent.kind == kindArray {
+			index, _ := arrayIndex(parent.items, l.positions[i], false)
+			parent.setItem(index, container, identifiers)
 		} else {
-			keys = append(keys, part)
-			newParts[partIndex] = part
+			parent.setField(l.positions[i], container)
 		}
+
+		container = parent
+	}
+
+	return container
+}
+
+func containerGet(container *node, key string) (*node, error) {
+	if container.kind != kindArray {
+		child, _ := container.field(key)
+		return child, nil
+	}
+
+	_, items := container.children()
+	index, err := arrayIndex(items, key, false)
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.Join(newParts, "/"), nil
+	return items[index], nil
 }
 
-func fixEndOfArrayPaths(doc []byte, patchObj jsonpatch.Patch) jsonpatch.Patch {
-	for _, patch := range patchObj {
-		if path, err := patch.Path(); err == nil && path != "unknown" {
-			pathParts := strings.Split(path, "/")
+func containerAdd(container *node, key string, child *node) (*node, error) {
+	if container.kind == kindNull {
+		return nil, jsonpatch.ErrInvalid
+	}
 
-			// last element is index?
-			if index, err := strconv.Atoi(pathParts[len(pathParts)-1]); err == nil {
-				jsonparserParts := pathParts[1 : len(pathParts)-1]
+	if container.kind != kindArray {
+		container = container.built()
+		container.setField(key, child)
+		return container, nil
+	}
 
-				// if last parent a array
-				lastIndex := 0
-				_, err := jsonparser.ArrayEach(doc, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-					lastIndex++
-				}, jsonparserParts...)
-				if err == nil {
-					// if after last index -> replace by -
-					if index >= lastIndex {
-						pathParts[len(pathParts)-1] = "-"
-					}
-				}
+	_, items := container.children()
+	index := len(items)
+	if key != "-" {
+		var err error
+		if index, err = strconv.Atoi(key); err != nil {
+			return nil, fmt.Errorf("value was not a proper array index: '%s': %w", key, err)
+		}
+
+		size := len(items) + 1
+		if index >= size {
+			return nil, fmt.Errorf("unable to access invalid index: %d: %w", index, jsonpatch.ErrInvalidIndex)
+		}
+		if index < 0 {
+			if index < -size {
+				return nil, fmt.Errorf("unable to access invalid index: %d: %w", index, jsonpatch.ErrInvalidIndex)
 			}
+			index += size
+		}
+	}
+
+	added := &node{kind: kindArray, items: make([]*node, 0, len(items)+1)}
+	added.items = append(added.items, items[:index]...)
+	added.items = append(added.items, child)
+	added.items = append(added.items, items[index:]...)
+	return added, nil
+}
 
-			patch["path"] = rawMessage(fmt.Sprintf("\"%s\"", strings.Join(pathParts, "/")))
+func containerRemove(container *node, key string) (*node, error) {
+	if container.kind != kindArray {
+		if _, ok := container.field(key); !ok {
+			return nil, fmt.Errorf("unable to remove nonexistent key: %s: %w", key, jsonpatch.ErrMissing)
 		}
 
-		if path, err := patch.From(); err == nil && path != "unknown" {
-			pathParts := strings.Split(path, "/")
+		container = container.built()
+		container.deleteField(key)
+		return container, nil
+	}
+
+	_, items := container.children()
+	index, err := arrayIndex(items, key, true)
+	if err != nil {
+		return nil, err
+	}
 
-			// last element is index?
-			if index, err := strconv.Atoi(pathParts[len(pathParts)-1]); err == nil {
-				jsonparserParts := pathParts[1 : len(pathParts)-1]
+	removed := &node{kind: kindArray, items: make([]*node, 0, len(items)-1)}
+	removed.items = append(removed.items, items[:index]...)
+	removed.items = append(removed.items, items[index+1:]...)
+	return removed, nil
+}
 
-				// if last parent a array
-				lastIndex := 0
-				_, err := jsonparser.ArrayEach(doc, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-					lastIndex++
-				}, jsonparserParts...)
-				if err == nil {
-					// if after last index -> replace by -
-					if index >= lastIndex {
-						pathParts[len(pathParts)-1] = "-"
-					}
-				}
-			}
+func containerSet(container *node, key string, child *node, identifiers [][]string) (*node, error) {
+	if container.kind != kindArray {
+		return containerAdd(container, key, child)
+	}
+
+	_, items := container.children()
+	index, err := arrayIndex(items, key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	container = container.built()
+	container.setItem(index, child, identifiers)
+	return container, nil
+}
+
+// arrayIndex parses an existing index. Negative indexes count from the end.
+func arrayIndex(items []*node, key string, remove bool) (int, error) {
+	index, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if remove && index >= len(items) {
+		return 0, fmt.Errorf("unable to access invalid index: %d: %w", index, jsonpatch.ErrInvalidIndex)
+	}
+
+	if index < 0 {
+		if index < -len(items) {
+			return 0, fmt.Errorf("unable to access invalid index: %d: %w", index, jsonpatch.ErrInvalidIndex)
+		}
+		index += len(items)
+	}
 
-			patch["from"] = rawMessage(fmt.Sprintf("\"%s\"", strings.Join(pathParts, "/")))
+	if index >= len(items) {
+		return 0, fmt.Errorf("unable to access invalid index: %d: %w", index, jsonpatch.ErrInvalidIndex)
+	}
+
+	return index, nil
+}
+
+var (
+	patchKeyDecoder = strings.NewReplacer("~1", "/", "~0", "~")
+	patchKeyEncoder = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+func encodePatchKey(key string) string {
+	return patchKeyEncoder.Replace(key)
+}
+
+func decodePatchKey(key string) string {
+	return patchKeyDecoder.Replace(key)
+}
+
+// replacePath replaces identifier segments like `/[id]` with the index of the
+// item. Invalid paths are kept for json-patch to report.
+func replacePath(root *node, path string, identifiers [][]string) (string, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return path, nil
+	}
+
+	resolved, err := p.resolve(root, identifiers)
+	if err != nil {
+		return "", err
+	}
+
+	return resolved.String(), nil
+}
+
+// fixEndOfArrayPath replaces an index after the last item of an array with `-`.
+// The array must be reachable by object keys only.
+func fixEndOfArrayPath(root *node, path string) string {
+	p, err := ParsePath(path)
+	if err != nil {
+		return path
+	}
+
+	last, ok := p.Last()
+	if !ok || last.Kind != SegmentIndex {
+		return path
+	}
+
+	current := root
+	for _, segment := range p.Parent() {
+		child, ok := current.field(segment.text())
+		if !ok {
+			return path
 		}
+		current = child
+	}
+
+	if current.kind != kindArray {
+		return path
+	}
+
+	if _, items := current.children(); last.Index >= len(items) {
+		return p.Parent().Append(End()).String()
 	}
 
-	return patchObj
+	return path
 }
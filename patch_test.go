@@ -101,6 +101,12 @@ func TestPatch(t *testing.T) {
 			want:      []byte(`[{"id":"ghi"},{"id":"abc"},{"id":"def"},{"id":"jkl"}]`),
 			wantError: false,
 		},
+		{
+			doc:       []byte(`{"list":[{"id":"a"},{"id":"b"},{"id":"c"}]}`),
+			patch:     []byte(`[{"op":"move","from":"/list/[c]","path":"/list/[c]"},{"op":"move","from":"/list/[a]","path":"/list/[a]"}]`),
+			want:      []byte(`{"list":[{"id":"a"},{"id":"b"},{"id":"c"}]}`),
+			wantError: false,
+		},
 		{
 			doc:       []byte(`{"id":"def"}`),
 			patch:     []byte(`[{"op":"remove","path":"/email"}]`),
@@ -158,6 +164,12 @@ func TestPatch(t *testing.T) {
 			want:      []byte(`{"abc":null,"foo":null,"hello":null}`),
 			wantError: false,
 		},
+		{
+			doc:       []byte(`{"files/x":[{"id":"src/main.go","size":1},{"id":"a]b","size":2}]}`),
+			patch:     []byte(`[{ "op": "replace", "path": "/files~1x/[src~1main.go]/size", "value": 3 }, { "op": "remove", "path": "/files~1x/[a]b]" }]`),
+			want:      []byte(`{"files/x":[{"id":"src/main.go","size":3}]}`),
+			wantError: false,
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -179,6 +191,18 @@ func TestPatch(t *testing.T) {
 	}
 }
 
+func TestPatchInvalidValue(t *testing.T) {
+	t.Parallel()
+
+	doc := []byte(`{"count":1}`)
+
+	_, err := patch(doc, []Operation{{Op: "add", Path: "/name", Value: rawMessage(`{"a":`)}}, nil)
+	assert.EqualError(t, err, "error in add for path: '/name': invalid value: unexpected end of JSON input")
+
+	_, err = patch(doc, []Operation{{Op: "replace", Path: "/count", Value: rawMessage(`[1,]`)}}, nil)
+	assert.EqualError(t, err, "error in replace for path: '/count': invalid value: invalid character ']' looking for beginning of value")
+}
+
 func BenchmarkPatch(b *testing.B) {
 	doc := []byte(`{ "count": 1 }`)
 	rawPatch := []byte(`[{ "op": "replace", "path": "/count", "value": 2 }]`)
@@ -245,14 +269,15 @@ func TestFixEndOfArrayPaths(t *testing.T) {
 			path:         "/array/3",
 			expectedPath: "/array/-",
 		},
+		{
+			doc:          []byte(`{"a/b":{"c~d":["a"]}}`),
+			path:         "/a~1b/c~0d/1",
+			expectedPath: "/a~1b/c~0d/-",
+		},
 	}
 
 	for i, testCase := range testCases {
-		patchObj := NewJsonpatchPatch([]Operation{{Op: "add", Path: testCase.path}})
-		patchObj = fixEndOfArrayPaths(testCase.doc, patchObj)
-
-		path, err := patchObj[0].Path()
-		assert.Nil(t, err)
+		path := fixEndOfArrayPath(parseRoot(testCase.doc), testCase.path)
 		assert.Equal(t, testCase.expectedPath, path, "Test case %d failed", i)
 	}
 }
@@ -0,0 +1,205 @@
+package pigeongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SegmentKind is the kind of a path segment.
+type SegmentKind int
+
+const (
+	// SegmentKey addresses an object key like `/name`.
+	SegmentKey SegmentKind = iota
+	// SegmentIndex addresses an array item by its position like `/users/0`.
+	SegmentIndex
+	// SegmentID addresses an array item by its identifier like `/users/[u1]`.
+	SegmentID
+	// SegmentEnd addresses the end of an array like `/users/-`.
+	SegmentEnd
+)
+
+// Segment is a decoded segment of a path.
+type Segment struct {
+	Kind SegmentKind
+	// Key is the unescaped object key of a key segment.
+	Key string
+	// ID is the unescaped identifier of an identifier segment.
+	ID string
+	// Index is the position of an index segment.
+	Index int
+}
+
+// Key returns a segment for an object key.
+func Key(key string) Segment {
+	return Segment{Kind: SegmentKey, Key: key}
+}
+
+// Index returns a segment for an array position.
+func Index(index int) Segment {
+	return Segment{Kind: SegmentIndex, Index: index}
+}
+
+// ID returns a segment for an array item with an identifier.
+func ID(id string) Segment {
+	return Segment{Kind: SegmentID, ID: id}
+}
+
+// End returns a segment for the end of an array.
+func End() Segment {
+	return Segment{Kind: SegmentEnd}
+}
+
+// parseSegment decodes an escaped segment. Identifiers are unescaped with the
+// brackets like PigeonJS, so they may contain `/` and `]`.
+func parseSegment(part string) Segment {
+	decoded := decodePatchKey(part)
+
+	switch {
+	case part == "-":
+		return End()
+	case len(decoded) >= 2 && decoded[0] == '[' && decoded[len(decoded)-1] == ']':
+		return ID(decoded[1 : len(decoded)-1])
+	}
+
+	// no signs or leading zeros, other numbers stay keys
+	if index, err := strconv.Atoi(part); err == nil && index >= 0 && strconv.Itoa(index) == part {
+		return Index(index)
+	}
+
+	return Key(decoded)
+}
+
+// String returns the escaped segment.
+func (s Segment) String() string {
+	return encodePatchKey(s.text())
+}
+
+// text returns the unescaped segment. Index and end segments address the object
+// key of the same text in objects.
+func (s Segment) text() string {
+	switch s.Kind {
+	case SegmentIndex:
+		return strconv.Itoa(s.Index)
+	case SegmentID:
+		return "[" + s.ID + "]"
+	case SegmentEnd:
+		return "-"
+	default:
+		return s.Key
+	}
+}
+
+// Path is a parsed path like `/users/[u1]/name`. The empty path is the document.
+// Segments are escaped like RFC 6901, `~` becomes `~0` and `/` becomes `~1`.
+type Path []Segment
+
+// ParsePath parses a path. Keys, that look like an index or `-`, are parsed as
+// index or end segments and address the object key of the same text.
+func ParsePath(path string) (Path, error) {
+	if path == "" {
+		return Path{}, nil
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path error: path %s must start with /", path)
+	}
+
+	parts := strings.Split(path[1:], "/")
+	p := make(Path, len(parts))
+	for i, part := range parts {
+		p[i] = parseSegment(part)
+	}
+
+	return p, nil
+}
+
+// String returns the escaped path, that can be used in `Operation.Path`.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, segment := range p {
+		b.WriteByte('/')
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}
+
+// Parent returns the path without the last segment. The parent of the document is the document.
+func (p Path) Parent() Path {
+	if len(p) == 0 {
+		return Path{}
+	}
+
+	return append(Path{}, p[:len(p)-1]...)
+}
+
+// Last returns the last segment. It returns false for the document.
+func (p Path) Last() (Segment, bool) {
+	if len(p) == 0 {
+		return Segment{}, false
+	}
+
+	return p[len(p)-1], true
+}
+
+// Append returns a new path with the segments appended.
+func (p Path) Append(segments ...Segment) Path {
+	appended := make(Path, 0, len(p)+len(segments))
+	appended = append(appended, p...)
+	return append(appended, segments...)
+}
+
+// Resolve replaces the identifier segments with the index of the item in the
+// document, like the paths are resolved before a patch.
+func (p Path) Resolve(d *Document) (Path, error) {
+	return p.resolve(d.root, d.identifiers)
+}
+
+// resolve replaces the identifier segments with the index of the item. Other
+// segments are kept, missing values are reported by the patch.
+func (p Path) resolve(root *node, identifiers [][]string) (Path, error) {
+	resolved := make(Path, len(p))
+
+	current := root
+	for i, segment := range p {
+		resolved[i] = segment
+
+		if segment.Kind == SegmentID {
+			if current == nil || current.kind != kindArray {
+				return nil, errors.New("id `" + segment.ID + "` not found")
+			}
+
+			index, ok := current.indexOf(segment.ID, identifiers)
+			if !ok {
+				return nil, errors.New("id `" + segment.ID + "` not found")
+			}
+
+			_, items := current.children()
+			resolved[i] = Index(index)
+			current = items[index]
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch current.kind {
+		case kindObject:
+			current, _ = current.field(segment.text())
+		case kindArray:
+			_, items := current.children()
+			if segment.Kind == SegmentIndex && segment.Index < len(items) {
+				current = items[segment.Index]
+			} else {
+				current = nil
+			}
+		default:
+			current = nil
+		}
+	}
+
+	return resolved, nil
+}
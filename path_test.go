@@ -0,0 +1,111 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		path string
+		want Path
+	}{
+		{path: "", want: Path{}},
+		{path: "/", want: Path{Key("")}},
+		{path: "/users/[u1]/name", want: Path{Key("users"), ID("u1"), Key("name")}},
+		{path: "/users/0/tags/-", want: Path{Key("users"), Index(0), Key("tags"), End()}},
+		{path: "/a~1b/c~0d", want: Path{Key("a/b"), Key("c~d")}},
+		{path: "/files/[src~1main.go]", want: Path{Key("files"), ID("src/main.go")}},
+		{path: "/files/[a]b]", want: Path{Key("files"), ID("a]b")}},
+		{path: "/files/[]", want: Path{Key("files"), ID("")}},
+		{path: "/01/-1/+1/1.5", want: Path{Key("01"), Key("-1"), Key("+1"), Key("1.5")}},
+		{path: "/12/~01", want: Path{Index(12), Key("~1")}},
+	}
+
+	for _, testCase := range testCases {
+		p, err := ParsePath(testCase.path)
+		assert.Nil(t, err, testCase.path)
+		assert.Equal(t, testCase.want, p, testCase.path)
+		assert.Equal(t, testCase.path, p.String(), testCase.path)
+	}
+
+	_, err := ParsePath("users/0")
+	assert.EqualError(t, err, "path error: path users/0 must start with /")
+}
+
+func TestPathString(t *testing.T) {
+	t.Parallel()
+
+	p := Path{Key("https://example.com"), Key("files"), ID("docs/~readme"), Key("size")}
+	assert.Equal(t, "/https:~1~1example.com/files/[docs~1~0readme]/size", p.String())
+
+	parsed, err := ParsePath(p.String())
+	assert.Nil(t, err)
+	assert.Equal(t, p, parsed)
+
+	// keys, that look like an index, address the same key
+	assert.Equal(t, "/0/-", Path{Key("0"), Key("-")}.String())
+}
+
+func TestPathHelpers(t *testing.T) {
+	t.Parallel()
+
+	p := Path{Key("users"), ID("u1")}
+
+	assert.Equal(t, Path{Key("users")}, p.Parent())
+	assert.Equal(t, Path{}, Path{}.Parent())
+
+	last, ok := p.Last()
+	assert.True(t, ok)
+	assert.Equal(t, ID("u1"), last)
+	_, ok = Path{}.Last()
+	assert.False(t, ok)
+
+	// append doesn't share memory with the path
+	name := p.Append(Key("name"))
+	email := p.Append(Key("email"))
+	assert.Equal(t, "/users/[u1]/name", name.String())
+	assert.Equal(t, "/users/[u1]/email", email.String())
+	assert.Equal(t, "/users/[u1]", p.String())
+}
+
+func TestPathResolve(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{
+		"boards": [{"id": "b1", "columns": [{"id": "c1"}, {"id": "c/2"}]}],
+		"a/b": {"users": [{"id": "u1"}, {"id": "u2"}]}
+	}`))
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		path string
+		want string
+		err  string
+	}{
+		{path: "/boards/[b1]/columns/[c~12]/id", want: "/boards/0/columns/1/id"},
+		{path: "/boards/0/columns/[c1]", want: "/boards/0/columns/0"},
+		{path: "/a~1b/users/[u2]", want: "/a~1b/users/1"},
+		{path: "/a~1b/users/-", want: "/a~1b/users/-"},
+		{path: "/missing/name", want: "/missing/name"},
+		{path: "/a~1b/users/[u3]", err: "id `u3` not found"},
+		{path: "/a~1b/[u1]", err: "id `u1` not found"},
+	}
+
+	for _, testCase := range testCases {
+		p, err := ParsePath(testCase.path)
+		assert.Nil(t, err)
+
+		resolved, err := p.Resolve(doc)
+		if testCase.err != "" {
+			assert.EqualError(t, err, testCase.err, testCase.path)
+			continue
+		}
+
+		assert.Nil(t, err, testCase.path)
+		assert.Equal(t, testCase.want, resolved.String(), testCase.path)
+	}
+}
@@ -0,0 +1,48 @@
+package pigeongo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTestFailed is returned and reported by Warnings for a change, whose `test`
+// operation doesn't hold.
+var ErrTestFailed = errors.New("test failed")
+
+// failedTest returns the index of the first `test` operation, that doesn't hold
+// before the change is applied. The operations before the test are applied to a
+// scratch tree, failing operations are left to the patch.
+func (d *Document) failedTest(operations []Operation) (int, error) {
+	tested := false
+	for _, operation := range operations {
+		tested = tested || operation.Op == "test"
+	}
+	if !tested {
+		return -1, nil
+	}
+
+	root := d.root
+	for i, operation := range operations {
+		patched, _, err := patchTree(root, []Operation{operation}, d.identifiers)
+		if err != nil {
+			if operation.Op == "test" {
+				return i, fmt.Errorf("%w: %s", ErrTestFailed, err.Error())
+			}
+			continue
+		}
+		root = patched
+	}
+
+	return -1, nil
+}
+
+// withoutTests removes the `test` operations.
+func withoutTests(operations []Operation) []Operation {
+	result := make([]Operation, 0, len(operations))
+	for _, operation := range operations {
+		if operation.Op != "test" {
+			result = append(result, operation)
+		}
+	}
+	return result
+}
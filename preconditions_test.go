@@ -0,0 +1,185 @@
+package pigeongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyOperation(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"cards":[{"id":"c1","title":"A"},{"id":"c2","title":"B"}],"tags":["x"]}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "copy", From: "/cards/[c1]/title", Path: "/cards/[c2]/title"}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "copy", From: "/tags/0", Path: "/tags/0"}},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "change2",
+	}))
+	assert.JSONEq(t, `{"cards":[{"id":"c1","title":"A"},{"id":"c2","title":"A"}],"tags":["x","x"]}`, string(doc.JSON()))
+
+	// the overwritten key is the previous value, array items are inserted
+	history := doc.History()
+	assert.Equal(t, `"B"`, string(*history[1].Diff[0].Prev))
+	assert.Nil(t, history[2].Diff[0].Prev)
+
+	// the copies are reversed and copy the new value
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/cards/[c1]/title", Value: rawMessage(`"C"`)}, {Op: "replace", Path: "/tags/0", Value: rawMessage(`"y"`)}},
+		TimestampMillis: 5,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}))
+	assert.JSONEq(t, `{"cards":[{"id":"c1","title":"C"},{"id":"c2","title":"C"}],"tags":["y","y"]}`, string(doc.JSON()))
+
+	change, err := doc.Blame("/cards/[c2]/title")
+	assert.Nil(t, err)
+	assert.Equal(t, "change1", change.ChangeID)
+
+	// undo restores the overwritten value
+	_, err = doc.Undo("client1")
+	assert.Nil(t, err)
+	_, err = doc.Undo("client1")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"cards":[{"id":"c1","title":"C"},{"id":"c2","title":"B"}],"tags":["y"]}`, string(doc.JSON()))
+}
+
+func TestTestOperation(t *testing.T) {
+	t.Parallel()
+
+	guarded := Change{
+		Diff: []Operation{
+			{Op: "test", Path: "/cards/[c1]/status", Value: rawMessage(`"open"`)},
+			{Op: "replace", Path: "/cards/[c1]/status", Value: rawMessage(`"closed"`)},
+		},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "guarded",
+	}
+	raw := []byte(`{"cards":[{"id":"c1","status":"open"}]}`)
+
+	t.Run("precondition", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := NewDocument(raw)
+		assert.Nil(t, err)
+
+		assert.Nil(t, doc.ApplyChange(guarded))
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"closed"}]}`, string(doc.JSON()))
+
+		// the test doesn't hold anymore
+		again := guarded
+		again.ChangeID = "again"
+		again.TimestampMillis = 30
+		err = doc.ApplyChange(again)
+		assert.True(t, errors.Is(err, ErrTestFailed))
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"closed"}]}`, string(doc.JSON()))
+		assert.Len(t, doc.History(), 2)
+	})
+
+	t.Run("escaped strings", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := NewDocument([]byte(`{"title":"Tom & Jerry","tag":"<b>","quote":"\u0022a\u0022"}`))
+		assert.Nil(t, err)
+
+		// strings are equal independent of their escaping
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff: []Operation{
+				{Op: "test", Path: "/title", Value: rawMessage(`"Tom & Jerry"`)},
+				{Op: "test", Path: "/title", Value: rawMessage(`"Tom \u0026 Jerry"`)},
+				{Op: "test", Path: "/tag", Value: rawMessage(`"<b>"`)},
+				{Op: "test", Path: "/quote", Value: rawMessage(`"\"a\""`)},
+				{Op: "replace", Path: "/title", Value: rawMessage(`"Tom"`)},
+			},
+			TimestampMillis: 20,
+			ClientID:        "client1",
+			ChangeID:        "escaped",
+		}))
+		assert.JSONEq(t, `{"title":"Tom","tag":"<b>","quote":"\"a\""}`, string(doc.JSON()))
+
+		err = doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "test", Path: "/tag", Value: rawMessage(`"<i>"`)}},
+			TimestampMillis: 30,
+			ClientID:        "client1",
+			ChangeID:        "different",
+		})
+		assert.True(t, errors.Is(err, ErrTestFailed))
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := NewDocument([]byte(`{"cards":[{"id":"c1","status":"done"}]}`), WithLenientApply())
+		assert.Nil(t, err)
+
+		assert.Nil(t, doc.ApplyChange(guarded))
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"done"}]}`, string(doc.JSON()))
+		assert.Len(t, doc.History(), 1)
+		assert.Len(t, doc.Warnings(), 1)
+		assert.Equal(t, PhasePatch, doc.Warnings()[0].Phase)
+		assert.Equal(t, 0, doc.Warnings()[0].OpIndex)
+		assert.True(t, errors.Is(doc.Warnings()[0].Err, ErrTestFailed))
+	})
+
+	t.Run("fast forward", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := NewDocument(raw)
+		assert.Nil(t, err)
+		assert.Nil(t, doc.ApplyChange(guarded))
+
+		// a late change invalidates the test, the guarded change is dropped
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "replace", Path: "/cards/[c1]/status", Value: rawMessage(`"archived"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client2",
+			ChangeID:        "late",
+		}))
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"archived"}]}`, string(doc.JSON()))
+		assert.Len(t, doc.History(), 2)
+		assert.Equal(t, "late", doc.History()[1].ChangeID)
+		assert.Len(t, doc.Warnings(), 1)
+		assert.Equal(t, Warning{ChangeID: "guarded", Phase: PhaseFastForward, OpIndex: 0, Err: doc.Warnings()[0].Err}, doc.Warnings()[0])
+
+		// replicas with the other order converge
+		other, err := NewDocument(raw)
+		assert.Nil(t, err)
+		assert.Nil(t, other.ApplyChanges([]Change{doc.History()[1]}))
+		assert.True(t, errors.Is(other.ApplyChange(guarded), ErrTestFailed))
+		assert.Equal(t, string(doc.JSON()), string(other.JSON()))
+	})
+
+	t.Run("rewind", func(t *testing.T) {
+		t.Parallel()
+
+		doc, err := NewDocument(raw)
+		assert.Nil(t, err)
+		assert.Nil(t, doc.ApplyChange(guarded))
+
+		// the test holds before and after the late change
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "add", Path: "/cards/[c1]/title", Value: rawMessage(`"Card"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client2",
+			ChangeID:        "late",
+		}))
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"closed","title":"Card"}]}`, string(doc.JSON()))
+		assert.Len(t, doc.History(), 3)
+		assert.Empty(t, doc.Warnings())
+
+		// undo doesn't test the value of the undone change
+		_, err = doc.Undo("client1")
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"cards":[{"id":"c1","status":"open","title":"Card"}]}`, string(doc.JSON()))
+	})
+}
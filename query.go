@@ -0,0 +1,89 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Match is a value found by Query.
+type Match struct {
+	// Path is the canonical path of the value. Array items with an identifier
+	// are addressed like `/users/[u1]`, all other items by their index.
+	Path  string
+	Value json.RawMessage
+}
+
+// Query returns all values matching pattern. Patterns use the path syntax of the
+// changes, `*` matches all keys of an object or all items of an array, like
+// `/users/*/name` or `/boards/[b1]/columns/*/cards/*`. Object keys are matched
+// in sorted order, array items in their order.
+func (d *Document) Query(pattern string) ([]Match, error) {
+	parts, err := ParsePath(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("query error: pattern %s must start with /", pattern)
+	}
+
+	if d.root.kind == kindInvalid {
+		return []Match{}, nil
+	}
+
+	matches := []Match{}
+	d.query(d.root, Path{}, parts, &matches)
+
+	return matches, nil
+}
+
+func (d *Document) query(n *node, path Path, parts Path, matches *[]Match) {
+	if len(parts) == 0 {
+		*matches = append(*matches, Match{
+			Path:  path.String(),
+			Value: append(json.RawMessage{}, n.JSON()...),
+		})
+		return
+	}
+
+	part, rest := parts[0], parts[1:]
+
+	if part.Kind != SegmentKey || part.Key != "*" {
+		child, index, ok := d.child(n, part)
+		if !ok {
+			return
+		}
+		d.query(child, path.Append(d.segment(n, child, part, index)), rest, matches)
+		return
+	}
+
+	fields, items := n.children()
+	switch n.kind {
+	case kindObject:
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			d.query(fields[key], path.Append(Key(key)), rest, matches)
+		}
+	case kindArray:
+		for index, item := range items {
+			d.query(item, path.Append(d.segment(n, item, part, index)), rest, matches)
+		}
+	default:
+		// values have no children
+	}
+}
+
+// segment returns the canonical path segment of a child.
+func (d *Document) segment(parent, child *node, part Segment, index int) Segment {
+	if parent.kind != kindArray {
+		return part
+	}
+
+	if id := child.itemID(d.identifiers); id != "" {
+		return ID(id)
+	}
+
+	return Index(index)
+}
@@ -0,0 +1,101 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{
+		"users": [{"id": "u1", "name": "Alice"}, {"id": "u2", "name": "Bob"}, {"name": "Anonymous"}, {"id": "x/y]", "name": "Eve"}],
+		"boards": [{"id": "b1", "columns": [{"id": "c1", "cards": [{"id": "k1"}, "note"]}, {"id": "c2", "cards": []}]}],
+		"settings": {"a/b": 1, "theme": "dark"}
+	}`), WithIdentifiers([][]string{{"id"}}))
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		pattern string
+		want    []Match
+	}{
+		{
+			pattern: "/users/*/name",
+			want: []Match{
+				{Path: "/users/[u1]/name", Value: []byte(`"Alice"`)},
+				{Path: "/users/[u2]/name", Value: []byte(`"Bob"`)},
+				{Path: "/users/2/name", Value: []byte(`"Anonymous"`)},
+				{Path: "/users/[x~1y]]/name", Value: []byte(`"Eve"`)},
+			},
+		},
+		{
+			pattern: "/boards/[b1]/columns/*/cards/*",
+			want: []Match{
+				{Path: "/boards/[b1]/columns/[c1]/cards/[k1]", Value: []byte(`{"id":"k1"}`)},
+				{Path: "/boards/[b1]/columns/[c1]/cards/1", Value: []byte(`"note"`)},
+			},
+		},
+		{
+			pattern: "/boards/0/columns/[c2]",
+			want: []Match{
+				{Path: "/boards/[b1]/columns/[c2]", Value: []byte(`{"id":"c2","cards":[]}`)},
+			},
+		},
+		{
+			pattern: "/settings/*",
+			want: []Match{
+				{Path: "/settings/a~1b", Value: []byte(`1`)},
+				{Path: "/settings/theme", Value: []byte(`"dark"`)},
+			},
+		},
+		{
+			pattern: "/*/*/id",
+			want: []Match{
+				{Path: "/boards/[b1]/id", Value: []byte(`"b1"`)},
+				{Path: "/users/[u1]/id", Value: []byte(`"u1"`)},
+				{Path: "/users/[u2]/id", Value: []byte(`"u2"`)},
+				{Path: "/users/[x~1y]]/id", Value: []byte(`"x/y]"`)},
+			},
+		},
+		{pattern: "/users/*/email", want: []Match{}},
+		{pattern: "/users/[u3]/*", want: []Match{}},
+		{pattern: "/settings/theme/*", want: []Match{}},
+	}
+
+	for _, testCase := range testCases {
+		matches, err := doc.Query(testCase.pattern)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.want, matches, testCase.pattern)
+	}
+
+	matches, err := doc.Query("")
+	assert.Nil(t, err)
+	assert.Len(t, matches, 1)
+
+	_, err = doc.Query("users/*")
+	assert.EqualError(t, err, "query error: pattern users/* must start with /")
+}
+
+func TestQueryPathsApply(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"users":[{"id":"u1","name":"Alice"},{"id":"u2","name":"Bob"}]}`))
+	assert.Nil(t, err)
+
+	matches, err := doc.Query("/users/*/name")
+	assert.Nil(t, err)
+
+	operations := []Operation{}
+	for _, match := range matches {
+		operations = append(operations, Operation{Op: "replace", Path: match.Path, Value: rawMessage(`"anonymous"`)})
+	}
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            operations,
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+	assert.Equal(t, `{"users":[{"id":"u1","name":"anonymous"},{"id":"u2","name":"anonymous"}]}`, string(doc.JSON()))
+}
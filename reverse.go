@@ -1,10 +1,5 @@
 package pigeongo
 
-import (
-	"strconv"
-	"strings"
-)
-
 func reverse(operations []Operation, identifiers [][]string) []Operation {
 	reversedOperations := make([]Operation, len(operations))
 
@@ -23,27 +18,67 @@ func reverse(operations []Operation, identifiers [][]string) []Operation {
 				id = findID(*operation.Value, identifiers)
 			}
 			if id != "" {
-				parts := strings.Split(operation.Path, "/")
-				// if last part is an index position
-				if _, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
-					// replace /array/0 with /array/[objId]
-					parts[len(parts)-1] = "[" + id + "]"
-					operation.Path = strings.Join(parts, "/")
-				} else if strings.HasPrefix(parts[len(parts)-1], "[") && strings.HasSuffix(parts[len(parts)-1], "]") {
-					// replace /array/[insertBeforeThisId] with /array/[objId]
-					parts[len(parts)-1] = "[" + id + "]"
-					operation.Path = strings.Join(parts, "/")
+				// replace /array/0 or /array/[insertBeforeThisId] with /array/[objId]
+				if p, err := ParsePath(operation.Path); err == nil {
+					if last, ok := p.Last(); ok && (last.Kind == SegmentIndex || last.Kind == SegmentID) {
+						operation.Path = p.Parent().Append(ID(id)).String()
+					}
+				}
+			}
+		case "move":
+			// move the item from its identifier path back to its original position
+			from := operation.Path
+			if operation.Prev != nil {
+				if id := findID(*operation.Prev, identifiers); id != "" {
+					if p, err := ParsePath(operation.Path); err == nil {
+						if last, ok := p.Last(); ok && last.Kind != SegmentKey {
+							from = p.Parent().Append(ID(id)).String()
+						}
+					}
 				}
 			}
+
+			if operation.Anchor != "" {
+				operation.Path = operation.Anchor
+			} else {
+				operation.Path = operation.From
+			}
+			operation.From = from
+			operation.Anchor = ""
+
+			// the moved value stays the previous value
+			reversedOperations[len(operations)-1-i] = operation
+			continue
+		case "copy":
+			// a copy into an object key is reversed by the previous value, a copy
+			// into an array is removed at the position it was inserted
+			operation.Op = "remove"
+			if operation.Prev != nil {
+				operation.Op = "replace"
+			}
+			if operation.Anchor != "" {
+				operation.Path = operation.Anchor
+				operation.Anchor = ""
+			}
+			operation.From = ""
+		case "test":
+			// the document is the same before and after a test
+			reversedOperations[len(operations)-1-i] = operation
+			continue
 		case "remove":
 			operation.Op = "add"
 			operation.Value = nil
 
-			parts := strings.Split(operation.Path, "/")
-			if strings.HasPrefix(parts[len(parts)-1], "[") && strings.HasSuffix(parts[len(parts)-1], "]") {
-				parts[len(parts)-1] = "0"
+			// insert at the original position, changes without anchor like the
+			// changes of PigeonJS insert at the beginning
+			if operation.Anchor != "" {
+				operation.Path = operation.Anchor
+				operation.Anchor = ""
+			} else if p, err := ParsePath(operation.Path); err == nil {
+				if last, ok := p.Last(); ok && last.Kind == SegmentID {
+					operation.Path = p.Parent().Append(Index(0)).String()
+				}
 			}
-			operation.Path = strings.Join(parts, "/")
 		}
 
 		// switch value and prev
@@ -67,3 +102,98 @@ func reverse(operations []Operation, identifiers [][]string) []Operation {
 
 	return reversedOperations
 }
+
+// setPrevValues sets the previous values and anchors of the operations. Every
+// operation sees the document after the operations before it, like the reversed
+// operations are applied.
+func (d *Document) setPrevValues(operations []Operation) {
+	state := &Document{raw: d.raw, root: d.root, identifiers: d.identifiers}
+
+	for i := range operations {
+		operations[i].Anchor = ""
+
+		switch operations[i].Op {
+		case "add", "test":
+			operations[i].Prev = nil
+		case "move":
+			// the moved value and its position are needed to move it back
+			operations[i].Prev = state.getValue(operations[i].From)
+			operations[i].Anchor = state.anchor(operations[i].From)
+		case "copy":
+			// only an object key is overwritten, array items are inserted
+			operations[i].Prev = nil
+			if p, err := ParsePath(operations[i].Path); err == nil && len(p) > 0 {
+				if parent, ok := state.lookup(p.Parent().String()); ok && parent.kind == kindObject {
+					operations[i].Prev = state.getValue(operations[i].Path)
+				} else if ok && parent.kind == kindArray {
+					operations[i].Anchor = state.insertPosition(p)
+				}
+			}
+		default:
+			operations[i].Prev = state.getValue(operations[i].Path)
+		}
+
+		if operations[i].Op == "remove" {
+			operations[i].Value = nil
+			operations[i].Anchor = state.anchor(operations[i].Path)
+		}
+
+		if i == len(operations)-1 {
+			break
+		}
+
+		// failing operations are left to the patch
+		if root, _, err := patchTree(state.root, operations[i:i+1], d.identifiers); err == nil {
+			state.raw, state.root = nil, root
+		}
+	}
+}
+
+// insertPosition returns the index, at which an item is inserted into an array
+// by the path. It returns "" for paths, that don't resolve.
+func (d *Document) insertPosition(p Path) string {
+	resolved, err := p.resolve(d.root, d.identifiers)
+	if err != nil {
+		return ""
+	}
+
+	last, _ := resolved.Last()
+	switch last.Kind {
+	case SegmentIndex:
+		return resolved.String()
+	case SegmentEnd:
+		parent, _ := d.lookup(p.Parent().String())
+		_, items := parent.children()
+		return resolved.Parent().Append(Index(len(items))).String()
+	default:
+		return ""
+	}
+}
+
+// anchor returns the position of an array item, that reinserts it at the same
+// place. It is the identifier of the next item or the index of the item.
+func (d *Document) anchor(path string) string {
+	p, err := ParsePath(path)
+	if err != nil || len(p) == 0 {
+		return ""
+	}
+
+	parent, ok := d.lookup(p.Parent().String())
+	if !ok || parent.kind != kindArray {
+		return ""
+	}
+
+	last, _ := p.Last()
+	_, index, ok := d.child(parent, last)
+	if !ok {
+		return ""
+	}
+
+	if _, items := parent.children(); index+1 < len(items) {
+		if id := items[index+1].itemID(d.identifiers); id != "" {
+			return p.Parent().Append(ID(id)).String()
+		}
+	}
+
+	return p.Parent().Append(Index(index)).String()
+}
@@ -2,6 +2,7 @@ package pigeongo
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -156,6 +157,76 @@ func TestReverse(t *testing.T) {
 				},
 			},
 		},
+		{
+			operations: []Operation{{
+				Op:    "add",
+				Path:  "/files~1x/[a~1b]",
+				Value: rawMessage(`{"id": "src/main.go"}`),
+			}, {
+				Op:   "remove",
+				Path: "/files~1x/[a]b]",
+				Prev: rawMessage(`{"id": "a]b"}`),
+			}},
+			expected: []Operation{{
+				Op:    "add",
+				Path:  "/files~1x/0",
+				Value: rawMessage(`{"id": "a]b"}`),
+			}, {
+				Op:   "remove",
+				Path: "/files~1x/[src~1main.go]",
+				Prev: rawMessage(`{"id": "src/main.go"}`),
+			}},
+		},
+		{
+			operations: []Operation{{
+				Op:   "copy",
+				From: "/cards/[c1]/title",
+				Path: "/cards/[c2]/title",
+				Prev: rawMessage(`"B"`),
+			}, {
+				Op:   "copy",
+				From: "/tags/0",
+				Path: "/tags/1",
+			}, {
+				Op:    "test",
+				Path:  "/count",
+				Value: rawMessage(`1`),
+			}},
+			expected: []Operation{{
+				Op:    "test",
+				Path:  "/count",
+				Value: rawMessage(`1`),
+			}, {
+				Op:   "remove",
+				Path: "/tags/1",
+			}, {
+				Op:    "replace",
+				Path:  "/cards/[c2]/title",
+				Value: rawMessage(`"B"`),
+			}},
+		},
+		{
+			operations: []Operation{{
+				Op:     "remove",
+				Path:   "/cards/[c2]",
+				Prev:   rawMessage(`{"id": "c2"}`),
+				Anchor: "/cards/[c3]",
+			}, {
+				Op:     "remove",
+				Path:   "/tags/1",
+				Prev:   rawMessage(`"b"`),
+				Anchor: "/tags/1",
+			}},
+			expected: []Operation{{
+				Op:    "add",
+				Path:  "/tags/1",
+				Value: rawMessage(`"b"`),
+			}, {
+				Op:    "add",
+				Path:  "/cards/[c3]",
+				Value: rawMessage(`{"id": "c2"}`),
+			}},
+		},
 	}
 
 	for i, testCase := range testCases {
@@ -185,3 +256,261 @@ func BenchmarkReverse(b *testing.B) {
 		reverse(operations, [][]string{{"id"}})
 	}
 }
+
+func TestReverseMove(t *testing.T) {
+	t.Parallel()
+
+	reversed := reverse([]Operation{{
+		Op:     "move",
+		From:   "/items/[a]",
+		Path:   "/items/2",
+		Prev:   rawMessage(`{"id":"a"}`),
+		Anchor: "/items/[b]",
+	}, {
+		Op:   "move",
+		From: "/a/b",
+		Path: "/c/d",
+		Prev: rawMessage(`1`),
+	}}, [][]string{{"id"}})
+
+	assert.Equal(t, []Operation{{
+		Op:   "move",
+		From: "/c/d",
+		Path: "/a/b",
+		Prev: rawMessage(`1`),
+	}, {
+		Op:   "move",
+		From: "/items/[a]",
+		Path: "/items/[b]",
+		Prev: rawMessage(`{"id":"a"}`),
+	}}, reversed)
+}
+
+func TestMoveRewind(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"items":[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"}],"tags":["x","y","z"]}`)
+
+	changes := []Change{
+		{
+			Diff:            []Operation{{Op: "move", From: "/items/[a]", Path: "/items/2"}, {Op: "move", From: "/tags/0", Path: "/tags/2"}},
+			TimestampMillis: 20,
+			ClientID:        "client1",
+			ChangeID:        "move",
+		},
+		{
+			Diff:            []Operation{{Op: "move", From: "/items/[d]", Path: "/items/[a]"}},
+			TimestampMillis: 30,
+			ClientID:        "client1",
+			ChangeID:        "before",
+		},
+		{
+			Diff:            []Operation{{Op: "add", Path: "/items/1", Value: rawMessage(`{"id":"e"}`)}, {Op: "add", Path: "/tags/1", Value: rawMessage(`"w"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client2",
+			ChangeID:        "late",
+		},
+	}
+
+	// the late change is applied after the moves are rewound
+	doc, err := NewDocument(raw)
+	assert.Nil(t, err)
+	for _, change := range changes {
+		assert.Nil(t, doc.ApplyChange(change))
+	}
+
+	expected, err := NewDocument(raw)
+	assert.Nil(t, err)
+	assert.Nil(t, expected.ApplyChanges(changes))
+
+	assert.Equal(t, `{"items":[{"id":"e"},{"id":"b"},{"id":"d"},{"id":"a"},{"id":"c"}],"tags":["w","y","x","z"]}`, string(expected.JSON()))
+	assert.JSONEq(t, string(expected.JSON()), string(doc.JSON()))
+
+	// the rewind restores the original order
+	rewound := doc.Clone()
+	assert.Nil(t, rewound.rewindWhile(func(Change) bool { return true }))
+	assert.JSONEq(t, string(raw), string(rewound.JSON()))
+}
+
+func TestMoveRewindDiff(t *testing.T) {
+	t.Parallel()
+
+	left, err := NewDocument([]byte(`{"items":[{"id":"a","v":1},{"id":"b","v":2},{"id":"c","v":3},{"id":"d","v":4}]}`))
+	assert.Nil(t, err)
+	right, err := NewDocument([]byte(`{"items":[{"id":"d","v":4},{"id":"b","v":2},{"id":"a","v":5},{"id":"c","v":3}]}`))
+	assert.Nil(t, err)
+
+	change, err := left.Diff(right)
+	assert.Nil(t, err)
+	change.TimestampMillis = 20
+	change.ClientID = "client1"
+	change.ChangeID = "diff"
+
+	late := Change{
+		Diff:            []Operation{{Op: "add", Path: "/items/1", Value: rawMessage(`{"id":"e","v":7}`)}, {Op: "replace", Path: "/items/[b]/v", Value: rawMessage(`6`)}},
+		TimestampMillis: 10,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}
+
+	doc := left.Clone()
+	assert.Nil(t, doc.ApplyChange(change))
+	assert.Nil(t, doc.ApplyChange(late))
+
+	expected := left.Clone()
+	assert.Nil(t, expected.ApplyChange(late))
+	assert.Nil(t, expected.ApplyChange(change))
+
+	assert.JSONEq(t, string(expected.JSON()), string(doc.JSON()))
+
+	// the rewind restores the original order
+	assert.Nil(t, doc.rewindWhile(func(Change) bool { return true }))
+	assert.JSONEq(t, string(left.JSON()), string(doc.JSON()))
+}
+
+func TestRemoveRewind(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"items":[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"}],"tags":["x","y","z"]}`)
+
+	doc, err := NewDocument(raw)
+	assert.Nil(t, err)
+
+	// anchors of other replicas are replaced by the own ones
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{Op: "remove", Path: "/items/[c]", Anchor: "/items/0"},
+			{Op: "remove", Path: "/items/[d]"},
+			{Op: "remove", Path: "/tags/1"},
+		},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "remove",
+	}))
+	assert.Equal(t, []string{"/items/[d]", "/items/2", "/tags/1"}, []string{
+		doc.History()[1].Diff[0].Anchor,
+		doc.History()[1].Diff[1].Anchor,
+		doc.History()[1].Diff[2].Anchor,
+	})
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "add", Path: "/items/[b]/name", Value: rawMessage(`"B"`)}},
+		TimestampMillis: 10,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}))
+	assert.JSONEq(t, `{"items":[{"id":"a"},{"id":"b","name":"B"}],"tags":["x","z"]}`, string(doc.JSON()))
+
+	// the removed items are inserted at their original position
+	rewound := doc.Clone()
+	assert.Nil(t, rewound.rewindWhile(func(Change) bool { return true }))
+	assert.JSONEq(t, string(raw), string(rewound.JSON()))
+
+	// undo, too
+	undo, err := NewDocument(raw)
+	assert.Nil(t, err)
+	assert.Nil(t, undo.ApplyChange(doc.History()[2]))
+	_, err = undo.Undo("client1")
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(raw), string(undo.JSON()))
+}
+
+func TestCopyRewind(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"items":[{"id":"a"},{"id":"b"}],"tags":["x","y"]}`)
+
+	testCases := []struct {
+		path   string
+		anchor string
+		json   string
+	}{
+		{path: "/tags/-", anchor: "/tags/2", json: `{"items":[{"id":"a","name":"A"},{"id":"b","name":"B"}],"tags":["x","y","x"]}`},
+		{path: "/tags/1", anchor: "/tags/1", json: `{"items":[{"id":"a","name":"A"},{"id":"b","name":"B"}],"tags":["x","x","y"]}`},
+		{path: "/items/[b]", anchor: "/items/1", json: `{"items":[{"id":"a","name":"A"},"x",{"id":"b","name":"B"}],"tags":["x","y"]}`},
+		{path: "/items/-", anchor: "/items/2", json: `{"items":[{"id":"a","name":"A"},{"id":"b","name":"B"},"x"],"tags":["x","y"]}`},
+	}
+
+	for _, testCase := range testCases {
+		doc, err := NewDocument(raw)
+		assert.Nil(t, err)
+
+		assert.Nil(t, doc.ApplyChange(Change{
+			Diff:            []Operation{{Op: "copy", From: "/tags/0", Path: testCase.path}},
+			TimestampMillis: 20,
+			ClientID:        "client1",
+			ChangeID:        "copy",
+		}), testCase.path)
+		assert.Equal(t, testCase.anchor, doc.History()[1].Diff[0].Anchor, testCase.path)
+
+		// the copy is removed at its position on every rewind
+		for i, id := range []string{"a", "b"} {
+			assert.Nil(t, doc.ApplyChange(Change{
+				Diff:            []Operation{{Op: "add", Path: "/items/[" + id + "]/name", Value: rawMessage(fmt.Sprintf("%q", strings.ToUpper(id)))}},
+				TimestampMillis: int64(10 + i),
+				ClientID:        "client2",
+				ChangeID:        "late" + id,
+			}), testCase.path)
+		}
+		assert.JSONEq(t, testCase.json, string(doc.JSON()), testCase.path)
+		assert.Empty(t, doc.Warnings(), testCase.path)
+
+		rewound := doc.Clone()
+		assert.Nil(t, rewound.rewindWhile(func(Change) bool { return true }), testCase.path)
+		assert.JSONEq(t, string(raw), string(rewound.JSON()), testCase.path)
+	}
+}
+
+func TestPrevValuesOfOneArray(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`{"items":[{"id":"a"},{"id":"b"},{"id":"c"},{"id":"d"}]}`)
+
+	doc, err := NewDocument(raw)
+	assert.Nil(t, err)
+
+	// every operation sees the array after the operations before it
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff: []Operation{
+			{Op: "remove", Path: "/items/0"},
+			{Op: "remove", Path: "/items/0"},
+			{Op: "move", From: "/items/0", Path: "/items/-"},
+			{Op: "add", Path: "/items/0", Value: rawMessage(`{"id":"e"}`)},
+			{Op: "copy", From: "/items/0/id", Path: "/items/-"},
+			{Op: "replace", Path: "/items/1", Value: rawMessage(`{"id":"f"}`)},
+		},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "change",
+	}))
+	assert.JSONEq(t, `{"items":[{"id":"e"},{"id":"f"},{"id":"c"},"e"]}`, string(doc.JSON()))
+
+	diff := doc.History()[1].Diff
+	prev := make([]string, len(diff))
+	anchors := make([]string, len(diff))
+	for i, operation := range diff {
+		if operation.Prev != nil {
+			prev[i] = string(*operation.Prev)
+		}
+		anchors[i] = operation.Anchor
+	}
+	assert.Equal(t, []string{`{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`, "", "", `{"id":"d"}`}, prev)
+	assert.Equal(t, []string{"/items/[b]", "/items/[c]", "/items/[d]", "", "/items/3", ""}, anchors)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "add", Path: "/items/[c]/name", Value: rawMessage(`"C"`)}},
+		TimestampMillis: 10,
+		ClientID:        "client2",
+		ChangeID:        "late",
+	}))
+	assert.JSONEq(t, `{"items":[{"id":"e"},{"id":"f"},{"id":"c","name":"C"},"e"]}`, string(doc.JSON()))
+
+	rewound := doc.Clone()
+	assert.Nil(t, rewound.rewindWhile(func(Change) bool { return true }))
+	assert.JSONEq(t, string(raw), string(rewound.JSON()))
+
+	// undo reverses the operations in the same order
+	_, err = doc.Undo("client1")
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"items":[{"id":"a"},{"id":"b"},{"id":"c","name":"C"},{"id":"d"}]}`, string(doc.JSON()))
+}
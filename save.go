@@ -0,0 +1,100 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// defaultHistoryLength is the history length used by PigeonJS.
+const defaultHistoryLength = 1000
+
+// WithHistoryLength sets the maximum number of history entries kept by LoadPigeonJS.
+// Older entries are dropped like PigeonJS `Pigeon.load` does. Use WithMaxHistory
+// to limit the history while changes are applied, it folds the loaded history, too.
+func WithHistoryLength(length int) DocumentOption {
+	return func(d *Document) {
+		d.historyLength = length
+	}
+}
+
+// pigeonJSDocument is the format of `Pigeon.save` and `Pigeon.load`.
+type pigeonJSDocument struct {
+	Meta pigeonJSMeta    `json:"meta"`
+	Data json.RawMessage `json:"data"`
+}
+
+type pigeonJSMeta struct {
+	History   []Change       `json:"history"`
+	Stash     []Change       `json:"stash"`
+	Warning   *string        `json:"warning"`
+	ChangeIDs map[string]int `json:"changeIds"`
+}
+
+// MarshalPigeonJS serializes the document in the format of PigeonJS `Pigeon.save`.
+func (d *Document) MarshalPigeonJS() ([]byte, error) {
+	return json.Marshal(pigeonJSDocument{
+		Meta: pigeonJSMeta{
+			History:   d.history,
+			Stash:     d.stash,
+			ChangeIDs: d.changeIDs,
+		},
+		Data: d.JSON(),
+	})
+}
+
+// LoadPigeonJS creates a document from the format of PigeonJS `Pigeon.save`.
+// The history is pruned like `Pigeon.load` to the length set by WithHistoryLength
+// and folded to the limits of WithMaxHistory and WithMaxHistoryAge.
+func LoadPigeonJS(raw []byte, opts ...DocumentOption) (*Document, error) {
+	var saved pigeonJSDocument
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, fmt.Errorf("load error: %s", err.Error())
+	}
+
+	if len(saved.Data) == 0 {
+		return nil, fmt.Errorf("load error: missing data")
+	}
+
+	doc, err := NewDocument(saved.Data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if saved.Meta.ChangeIDs != nil {
+		doc.changeIDs = saved.Meta.ChangeIDs
+	}
+
+	if len(saved.Meta.History) > 0 {
+		doc.history = saved.Meta.History
+	}
+
+	doc.pruneHistory(doc.historyLength)
+
+	// the stash contains rewound changes, that are missing in the data
+	if len(saved.Meta.Stash) > 0 {
+		doc.stash = saved.Meta.Stash
+		if err := doc.FastForwardChanges(); err != nil {
+			return nil, fmt.Errorf("load error: %s", err.Error())
+		}
+	}
+
+	if err := doc.limitHistory(); err != nil {
+		return nil, fmt.Errorf("load error: %s", err.Error())
+	}
+
+	return doc, nil
+}
+
+// pruneHistory drops the oldest history entries like PigeonJS `pruneHistory`.
+func (d *Document) pruneHistory(length int) {
+	if length <= 0 || len(d.history) <= length {
+		return
+	}
+
+	for _, change := range d.history[:len(d.history)-length] {
+		delete(d.changeIDs, change.ChangeID)
+	}
+
+	d.history = d.history[len(d.history)-length:]
+	d.checkpoints = nil
+}
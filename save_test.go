@@ -0,0 +1,130 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalPigeonJS(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp"}`))
+	assert.Nil(t, err)
+
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/name",
+				Value: rawMessage(`"Phil"`),
+			},
+		},
+		TimestampMillis: 2,
+		ClientID:        "50reifj9hyt",
+		Seq:             1,
+		ChangeID:        "dva96nqsdd",
+	})
+	assert.Nil(t, err)
+
+	raw, err := doc.MarshalPigeonJS()
+	assert.Nil(t, err)
+
+	var saved map[string]map[string]any
+	assert.Nil(t, json.Unmarshal(raw, &saved))
+	assert.Equal(t, map[string]any{"name": "Phil"}, saved["data"])
+	assert.Equal(t, map[string]any{"dva96nqsdd": float64(1)}, saved["meta"]["changeIds"])
+	assert.Len(t, saved["meta"]["history"], 2)
+	assert.Contains(t, saved["meta"], "stash")
+	assert.Contains(t, saved["meta"], "warning")
+
+	loaded, err := LoadPigeonJS(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, string(doc.JSON()), string(loaded.JSON()))
+	assert.Equal(t, doc.History(), loaded.History())
+
+	// known changes are skipped after loading
+	err = loaded.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/name",
+				Value: rawMessage(`"Hans"`),
+			},
+		},
+		TimestampMillis: 2,
+		ClientID:        "50reifj9hyt",
+		ChangeID:        "dva96nqsdd",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"Phil"}`, string(loaded.JSON()))
+}
+
+func TestLoadPigeonJS(t *testing.T) {
+	t.Parallel()
+
+	// saved by PigeonJS
+	raw := []byte(`{"meta":{"history":[` +
+		`{"diff":[{"op":"add","path":"/name","value":"Philipp"}],"client_id":"pk5sxv73ctn","timestamp_ms":1,"seq":0,"change_id":"a"},` +
+		`{"diff":[{"op":"replace","path":"/name","value":"Phil","_prev":"Philipp"}],"client_id":"pk5sxv73ctn","timestamp_ms":2,"seq":1,"change_id":"b"},` +
+		`{"diff":[{"op":"add","path":"/age","value":38}],"client_id":"pk5sxv73ctn","timestamp_ms":3,"seq":2,"change_id":"c"}` +
+		`],"stash":[],"warning":null,"changeIds":{"a":1,"b":1,"c":1}},"data":{"name":"Phil","age":38}}`)
+
+	doc, err := LoadPigeonJS(raw)
+	assert.Nil(t, err)
+	assert.Len(t, doc.History(), 3)
+	assert.Equal(t, `{"name":"Phil","age":38}`, string(doc.JSON()))
+
+	// late change is merged by timestamp
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/name",
+				Value: rawMessage(`"Hans"`),
+			},
+		},
+		TimestampMillis: 2,
+		ClientID:        "aaaaaaa",
+		ChangeID:        "d",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"age":38,"name":"Phil"}`, string(doc.JSON()))
+
+	// prune history like PigeonJS
+	doc, err = LoadPigeonJS(raw, WithHistoryLength(2))
+	assert.Nil(t, err)
+	assert.Len(t, doc.History(), 2)
+	assert.Equal(t, "b", doc.History()[0].ChangeID)
+	assert.NotContains(t, doc.changeIDs, "a")
+	assert.Contains(t, doc.changeIDs, "b")
+
+	// the history limit folds the loaded history
+	doc, err = LoadPigeonJS(raw, WithMaxHistory(1))
+	assert.Nil(t, err)
+	assert.Len(t, doc.History(), 1)
+	assert.Equal(t, "c", doc.History()[0].ChangeID)
+	assert.Equal(t, []Operation{
+		{Op: "add", Path: "/name", Value: rawMessage(`"Phil"`)},
+		{Op: "add", Path: "/age", Value: rawMessage("38")},
+	}, doc.History()[0].Diff)
+	assert.Contains(t, doc.changeIDs, "a")
+
+	// stash is applied on load
+	doc, err = LoadPigeonJS([]byte(`{"meta":{"history":[` +
+		`{"diff":[{"op":"add","path":"/name","value":"Philipp"}],"client_id":"pk5sxv73ctn","timestamp_ms":1,"seq":0,"change_id":"a"}` +
+		`],"stash":[` +
+		`{"diff":[{"op":"replace","path":"/name","value":"Phil","_prev":"Philipp"}],"client_id":"pk5sxv73ctn","timestamp_ms":2,"seq":1,"change_id":"b"}` +
+		`],"warning":null,"changeIds":{"a":1}},"data":{"name":"Philipp"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"Phil"}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 2)
+	assert.Contains(t, doc.changeIDs, "b")
+
+	// invalid documents
+	_, err = LoadPigeonJS([]byte(`{"meta":`))
+	assert.NotNil(t, err)
+	_, err = LoadPigeonJS([]byte(`{"meta":{}}`))
+	assert.Equal(t, "load error: missing data", err.Error())
+}
@@ -0,0 +1,309 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// SyncDocument wraps a Document for concurrent use. Writes are serialized and
+// readers always see a consistent state of the document and its history.
+type SyncDocument struct {
+	mu  sync.RWMutex
+	doc *Document
+}
+
+// NewSyncDocument creates a new concurrency-safe document.
+func NewSyncDocument(raw []byte, opts ...DocumentOption) (*SyncDocument, error) {
+	doc, err := NewDocument(raw, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyncDocument{doc: doc}, nil
+}
+
+// Sync wraps an existing document. The document must not be used directly afterwards.
+func Sync(doc *Document) *SyncDocument {
+	return &SyncDocument{doc: doc}
+}
+
+// Snapshot is a consistent copy of the document state and its history.
+type Snapshot struct {
+	JSON    []byte
+	History []Change
+}
+
+// Snapshot returns a copy of the current document and history taken at the same time.
+func (s *SyncDocument) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Snapshot{
+		JSON:    s.json(),
+		History: s.history(),
+	}
+}
+
+// JSON returns a copy of the current document.
+func (s *SyncDocument) JSON() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.json()
+}
+
+// Get returns a copy of the value at path.
+func (s *SyncDocument) Get(path string) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Get(path)
+}
+
+// Exists returns true, if a value exists at path.
+func (s *SyncDocument) Exists(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Exists(path)
+}
+
+// Query returns copies of all values matching pattern.
+func (s *SyncDocument) Query(pattern string) ([]Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Query(pattern)
+}
+
+// Resolve replaces the identifier segments of path with the index of the item.
+func (s *SyncDocument) Resolve(path Path) (Path, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return path.Resolve(s.doc)
+}
+
+// Checksum returns the checksum of the current document like PigeonJS `Pigeon.crc`.
+func (s *SyncDocument) Checksum() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Checksum()
+}
+
+// History returns a copy of the current history.
+func (s *SyncDocument) History() []Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.history()
+}
+
+// ChangesSince returns a copy of all changes after the change with changeID.
+func (s *SyncDocument) ChangesSince(changeID string) ([]Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes, err := s.doc.ChangesSince(changeID)
+	return copyChanges(changes), err
+}
+
+// ChangesSinceTimestamp returns a copy of all changes newer than timestampMillis.
+func (s *SyncDocument) ChangesSinceTimestamp(timestampMillis int64) ([]Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	changes, err := s.doc.ChangesSinceTimestamp(timestampMillis)
+	return copyChanges(changes), err
+}
+
+// Pending returns a copy of the changes, that wait for missing dependencies.
+func (s *SyncDocument) Pending() []Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copyChanges(s.doc.Pending())
+}
+
+// MissingDeps returns the changeIDs, that pending changes wait for.
+func (s *SyncDocument) MissingDeps() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.MissingDeps()
+}
+
+// Warnings returns a copy of the warnings of the last write. Use Write to read the
+// warnings of a specific change, other writes may replace them in between.
+func (s *SyncDocument) Warnings() []Warning {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Warning(nil), s.doc.Warnings()...)
+}
+
+// Blame returns the change, that wrote the value at path last.
+func (s *SyncDocument) Blame(path string) (Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Blame(path)
+}
+
+// BlameMap returns the change, that wrote a path last, for all paths written by the history.
+func (s *SyncDocument) BlameMap() (map[string]Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.BlameMap()
+}
+
+// At returns an independent copy of the document at the given time.
+func (s *SyncDocument) At(timestampMillis int64) (*Document, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.At(timestampMillis)
+}
+
+// DiffBetween returns the operations from the document at fromMillis to the
+// document at toMillis.
+func (s *SyncDocument) DiffBetween(fromMillis, toMillis int64) ([]Operation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.DiffBetween(fromMillis, toMillis)
+}
+
+// MarshalPigeonJS serializes the document in the format of PigeonJS `Pigeon.save`.
+func (s *SyncDocument) MarshalPigeonJS() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.MarshalPigeonJS()
+}
+
+// Clone returns an independent copy of the wrapped document.
+func (s *SyncDocument) Clone() *Document {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Clone()
+}
+
+// Diff creates a change from the current document to the right document.
+func (s *SyncDocument) Diff(right *Document) (Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.doc.Diff(right)
+}
+
+// ApplyChange to the document. It change nothing, if one operation failed.
+func (s *SyncDocument) ApplyChange(change Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.ApplyChange(change)
+}
+
+// ApplyChanges applies many changes with a single rewind and fast forward.
+func (s *SyncDocument) ApplyChanges(changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.ApplyChanges(changes)
+}
+
+// ReceiveChanges applies many changes and returns them with adjusted timestamps.
+func (s *SyncDocument) ReceiveChanges(changes []Change) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.ReceiveChanges(changes)
+}
+
+// Change edits a draft of the document and applies the resulting change.
+func (s *SyncDocument) Change(clientID string, fn func(draft *any) error) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.Change(clientID, fn)
+}
+
+// Commit applies the difference between the document and raw as new change.
+func (s *SyncDocument) Commit(clientID string, raw []byte) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.Commit(clientID, raw)
+}
+
+// Undo reverts the latest change of the client.
+func (s *SyncDocument) Undo(clientID string) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.Undo(clientID)
+}
+
+// Redo applies the latest undone change of the client again.
+func (s *SyncDocument) Redo(clientID string) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.Redo(clientID)
+}
+
+// ReduceHistory folds all changes before minTimestampMillis into the initial diff.
+func (s *SyncDocument) ReduceHistory(minTimestampMillis int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doc.ReduceHistory(minTimestampMillis)
+}
+
+// Observe registers an observer. Observers are called while the write lock is
+// held and must not call the SyncDocument. The returned function removes the observer.
+func (s *SyncDocument) Observe(observer Observer) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remove := s.doc.Observe(observer)
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		remove()
+	}
+}
+
+// Read calls fn with the wrapped document while holding the read lock.
+// fn must not modify the document or keep a reference to it.
+func (s *SyncDocument) Read(fn func(doc *Document)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn(s.doc)
+}
+
+// Write calls fn with the wrapped document while holding the write lock.
+// fn must not keep a reference to the document.
+func (s *SyncDocument) Write(fn func(doc *Document) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fn(s.doc)
+}
+
+func (s *SyncDocument) json() []byte {
+	raw := s.doc.JSON()
+	result := make([]byte, len(raw))
+	copy(result, raw)
+	return result
+}
+
+func (s *SyncDocument) history() []Change {
+	return copyChanges(s.doc.History())
+}
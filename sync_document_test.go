@@ -0,0 +1,124 @@
+package pigeongo
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncDocument(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewSyncDocument([]byte(`{"count":0,"items":{}}`))
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			assert.Nil(t, doc.ApplyChange(Change{
+				Diff: []Operation{
+					{
+						Op:    "add",
+						Path:  fmt.Sprintf("/items/item%d", i),
+						Value: rawMessage(fmt.Sprintf(`%d`, i)),
+					},
+				},
+				TimestampMillis: int64(i),
+				ClientID:        "client",
+				ChangeID:        fmt.Sprintf("change%d", i),
+			}))
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			snapshot := doc.Snapshot()
+			assert.NotEmpty(t, snapshot.JSON)
+			assert.NotEmpty(t, snapshot.History)
+
+			_, err := doc.Blame("/count")
+			assert.Nil(t, err)
+			_, err = doc.BlameMap()
+			assert.Nil(t, err)
+			_, err = doc.At(10)
+			assert.Nil(t, err)
+			_, err = doc.DiffBetween(0, 10)
+			assert.Nil(t, err)
+			_, err = doc.MarshalPigeonJS()
+			assert.Nil(t, err)
+			assert.Empty(t, doc.Warnings())
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, doc.History(), 21)
+
+	var items int
+	doc.Read(func(d *Document) {
+		items = len(d.History()) - 1
+	})
+	assert.Equal(t, 20, items)
+
+	assert.Nil(t, doc.ReduceHistory(100))
+	assert.Len(t, doc.History(), 1)
+
+	err = doc.Write(func(d *Document) error {
+		return d.ApplyChange(Change{
+			Diff: []Operation{
+				{
+					Op:    "replace",
+					Path:  "/count",
+					Value: rawMessage(`20`),
+				},
+			},
+			TimestampMillis: 200,
+			ClientID:        "client",
+			ChangeID:        "count",
+		})
+	})
+	assert.Nil(t, err)
+
+	snapshot := doc.Snapshot()
+	assert.Contains(t, string(snapshot.JSON), `"count":20`)
+	assert.Equal(t, "count", snapshot.History[len(snapshot.History)-1].ChangeID)
+
+	// clone is independent of the synchronized document
+	clone := doc.Clone()
+	assert.Equal(t, snapshot.JSON, clone.JSON())
+
+	// a late change on the clone doesn't touch the history of the synchronized document
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+
+		assert.Nil(t, clone.ApplyChange(Change{
+			Diff: []Operation{
+				{
+					Op:    "replace",
+					Path:  "/count",
+					Value: rawMessage(`10`),
+				},
+			},
+			TimestampMillis: 150,
+			ClientID:        "client",
+			ChangeID:        "late",
+		}))
+	}()
+	go func() {
+		defer wg.Done()
+
+		assert.Len(t, doc.History(), 2)
+	}()
+	wg.Wait()
+
+	history := doc.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, `0`, string(*history[1].Diff[0].Prev))
+	assert.Equal(t, `10`, string(*clone.History()[2].Diff[0].Prev))
+}
@@ -373,6 +373,32 @@ const testCases = [
                 { id: "ret34sdf4", name: "World" },
             ],
         },
+    },
+    {
+        oldDocument: {
+            "https://example.com/a": { "a~b": 1 },
+            "c/d": "Test",
+        },
+        newDocument: {
+            "https://example.com/a": { "a~b": 2, "~1": true },
+            "e/f~g": "Test",
+        },
+    },
+    {
+        oldDocument: {
+            files: [
+                { id: "src/main.go", size: 1 },
+                { id: "a]b", size: 2 },
+                { id: "x/y", size: 3 },
+            ],
+        },
+        newDocument: {
+            files: [
+                { id: "src/main.go", size: 4 },
+                { id: "docs/~readme", size: 5 },
+                { id: "x/y", size: 3 },
+            ],
+        },
     }
 ];
 
@@ -0,0 +1,40 @@
+package pigeongo
+
+import "fmt"
+
+// At returns a copy of the document at the given time. The copy contains all
+// changes up to and including timestampMillis. The document itself is unchanged.
+func (d *Document) At(timestampMillis int64) (*Document, error) {
+	if timestampMillis < d.history[0].TimestampMillis {
+		return nil, fmt.Errorf("time travel error: history starts at %d", d.history[0].TimestampMillis)
+	}
+
+	workingCopy := d.Clone()
+
+	if err := workingCopy.rewindWhile(func(change Change) bool {
+		return change.TimestampMillis > timestampMillis
+	}); err != nil {
+		return nil, fmt.Errorf("time travel error: %s", err.Error())
+	}
+
+	// drop the newer changes
+	workingCopy.stash = []Change{}
+
+	return workingCopy, nil
+}
+
+// DiffBetween returns the operations from the document at fromMillis to the
+// document at toMillis.
+func (d *Document) DiffBetween(fromMillis, toMillis int64) ([]Operation, error) {
+	from, err := d.At(fromMillis)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := d.At(toMillis)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(from.JSON(), to.JSON(), d.identifiers)
+}
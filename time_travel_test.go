@@ -0,0 +1,114 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAt(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp","cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+
+	for _, change := range []Change{
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"Phil"`)}},
+			TimestampMillis: 10,
+			ClientID:        "client1",
+			ChangeID:        "change1",
+		},
+		{
+			Diff:            []Operation{{Op: "add", Path: "/cards/1", Value: rawMessage(`{"id":"card2","text":"bar"}`)}},
+			TimestampMillis: 20,
+			ClientID:        "client1",
+			ChangeID:        "change2",
+		},
+		{
+			Diff:            []Operation{{Op: "replace", Path: "/cards/[card1]/text", Value: rawMessage(`"baz"`)}},
+			TimestampMillis: 20,
+			ClientID:        "client2",
+			ChangeID:        "change3",
+		},
+	} {
+		assert.Nil(t, doc.ApplyChange(change))
+	}
+
+	testCases := []struct {
+		timestampMillis int64
+		expected        string
+		history         int
+	}{
+		{timestampMillis: 0, expected: `{"name":"Philipp","cards":[{"id":"card1","text":"foo"}]}`, history: 1},
+		{timestampMillis: 9, expected: `{"cards":[{"id":"card1","text":"foo"}],"name":"Philipp"}`, history: 1},
+		{timestampMillis: 10, expected: `{"cards":[{"id":"card1","text":"foo"}],"name":"Phil"}`, history: 2},
+		{timestampMillis: 20, expected: `{"cards":[{"id":"card1","text":"baz"},{"id":"card2","text":"bar"}],"name":"Phil"}`, history: 4},
+		{timestampMillis: 100, expected: `{"cards":[{"id":"card1","text":"baz"},{"id":"card2","text":"bar"}],"name":"Phil"}`, history: 4},
+	}
+
+	for _, testCase := range testCases {
+		past, err := doc.At(testCase.timestampMillis)
+		assert.Nil(t, err)
+		assert.JSONEq(t, testCase.expected, string(past.JSON()))
+		assert.Len(t, past.History(), testCase.history)
+	}
+
+	// the document is unchanged
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"},{"id":"card2","text":"bar"}],"name":"Phil"}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 4)
+
+	// the past document can be changed
+	past, err := doc.At(10)
+	assert.Nil(t, err)
+	assert.Nil(t, past.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"Hans"`)}},
+		TimestampMillis: 30,
+		ClientID:        "client1",
+		ChangeID:        "change4",
+	}))
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Hans"}`, string(past.JSON()))
+
+	// before the history
+	assert.Nil(t, doc.ReduceHistory(15))
+	_, err = doc.At(5)
+	assert.Equal(t, "time travel error: history starts at 10", err.Error())
+}
+
+func TestDiffBetween(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp","cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"Phil"`)}},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+	assert.Nil(t, doc.ApplyChange(Change{
+		Diff:            []Operation{{Op: "replace", Path: "/cards/[card1]/text", Value: rawMessage(`"bar"`)}},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "change2",
+	}))
+
+	operations, err := doc.DiffBetween(10, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{
+		{
+			Op:    "replace",
+			Path:  "/cards/[card1]/text",
+			Value: rawMessage(`"bar"`),
+			Prev:  rawMessage(`"foo"`),
+		},
+	}, operations)
+
+	operations, err = doc.DiffBetween(20, 0)
+	assert.Nil(t, err)
+	assert.Len(t, operations, 2)
+
+	_, err = doc.DiffBetween(-1, 20)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,523 @@
+package pigeongo
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+type nodeKind int
+
+const (
+	kindInvalid nodeKind = iota
+	kindNull
+	kindBool
+	kindNumber
+	kindString
+	kindObject
+	kindArray
+)
+
+// node is an immutable JSON value. A patch copies the containers on the changed
+// path and shares all other nodes with the previous version of the document.
+//
+// Untouched containers keep their source bytes and are parsed lazily. Containers
+// walked through by a patch are built from their children and serialized with
+// sorted keys, exactly like json-patch serializes every container it parsed.
+type node struct {
+	kind nodeKind
+	// raw are the source bytes, nil for built containers
+	raw []byte
+
+	// children of built containers
+	fields map[string]*node
+	keys   []string
+	items  []*node
+
+	// caches, filled on first use. The identifiers of a tree never change.
+	view  atomic.Pointer[nodeView]
+	json  atomic.Pointer[[]byte]
+	id    atomic.Pointer[string]
+	vid   atomic.Pointer[string]
+	index atomic.Pointer[map[string]int]
+	vids  atomic.Pointer[map[string]int]
+}
+
+// nodeView are the lazily parsed children of a raw container.
+type nodeView struct {
+	fields map[string]*node
+	items  []*node
+}
+
+var nullNode = newNode([]byte("null"))
+
+// parseRoot creates the root node of a document. Like json-patch, documents
+// starting with `[` are arrays and all other documents must be objects.
+func parseRoot(raw []byte) *node {
+	if len(raw) == 0 || !json.Valid(raw) {
+		return &node{kind: kindInvalid, raw: raw}
+	}
+
+	root := newNode(raw)
+	switch {
+	case raw[0] == '[':
+		return root
+	case root.kind == kindObject, root.kind == kindNull:
+		return root
+	}
+
+	return &node{kind: kindInvalid, raw: raw}
+}
+
+// newNode creates a node from valid JSON.
+func newNode(raw []byte) *node {
+	raw = bytes.TrimSpace(raw)
+
+	n := &node{raw: raw}
+	switch raw[0] {
+	case '{':
+		n.kind = kindObject
+	case '[':
+		n.kind = kindArray
+	case '"':
+		n.kind = kindString
+	case 't', 'f':
+		n.kind = kindBool
+	case 'n':
+		n.kind = kindNull
+	default:
+		n.kind = kindNumber
+	}
+
+	return n
+}
+
+// valueNode creates a node from an operation value. A missing value is null.
+func valueNode(value *json.RawMessage) (*node, error) {
+	if value == nil || *value == nil {
+		return nullNode, nil
+	}
+
+	if !json.Valid(*value) {
+		var buf bytes.Buffer
+		return nil, json.Compact(&buf, *value)
+	}
+
+	return newNode(*value), nil
+}
+
+func (n *node) isContainer() bool {
+	return n.kind == kindObject || n.kind == kindArray
+}
+
+// isNull returns true for null values. json-patch stores them as nil nodes.
+func (n *node) isNull() bool {
+	return n == nil || n.kind == kindNull
+}
+
+// children returns the fields of an object or the items of an array.
+func (n *node) children() (map[string]*node, []*node) {
+	if n.raw == nil {
+		return n.fields, n.items
+	}
+
+	if !n.isContainer() {
+		return nil, nil
+	}
+
+	view := n.view.Load()
+	if view == nil {
+		view = parseView(n.raw, n.kind)
+		n.view.Store(view)
+	}
+
+	return view.fields, view.items
+}
+
+// field returns the child of an object.
+func (n *node) field(key string) (*node, bool) {
+	if n.kind != kindObject {
+		return nil, false
+	}
+
+	fields, _ := n.children()
+	child, ok := fields[key]
+	return child, ok
+}
+
+// built returns a copy of the container, that is serialized from its children.
+func (n *node) built() *node {
+	fields, items := n.children()
+
+	copied := &node{kind: n.kind}
+	switch n.kind {
+	case kindObject:
+		copied.fields = make(map[string]*node, len(fields))
+		for key, child := range fields {
+			copied.fields[key] = child
+		}
+
+		if n.raw == nil {
+			copied.keys = append([]string{}, n.keys...)
+		} else {
+			copied.keys = make([]string, 0, len(fields))
+			for key := range fields {
+				copied.keys = append(copied.keys, key)
+			}
+			sort.Strings(copied.keys)
+		}
+	case kindArray:
+		copied.items = append(make([]*node, 0, len(items)+1), items...)
+		copied.index.Store(n.index.Load())
+		copied.vids.Store(n.vids.Load())
+	case kindNull:
+		// the null root is an object without fields
+		copied.kind = kindNull
+		copied.raw = n.raw
+	default:
+		// values are never built
+	}
+
+	return copied
+}
+
+// deepBuilt returns a copy, where all containers are serialized from their children.
+func (n *node) deepBuilt() *node {
+	if !n.isContainer() {
+		return n
+	}
+
+	copied := n.built()
+	for key, child := range copied.fields {
+		copied.fields[key] = child.deepBuilt()
+	}
+	for i, child := range copied.items {
+		copied.items[i] = child.deepBuilt()
+	}
+
+	return copied
+}
+
+// setField sets a field of a built object.
+func (n *node) setField(key string, child *node) {
+	if _, ok := n.fields[key]; !ok {
+		i := sort.SearchStrings(n.keys, key)
+		n.keys = append(n.keys, "")
+		copy(n.keys[i+1:], n.keys[i:])
+		n.keys[i] = key
+	}
+	n.fields[key] = child
+}
+
+// deleteField removes a field of a built object.
+func (n *node) deleteField(key string) {
+	delete(n.fields, key)
+	i := sort.SearchStrings(n.keys, key)
+	n.keys = append(n.keys[:i], n.keys[i+1:]...)
+}
+
+// setItem replaces an item of a built array. The identifier indexes are kept,
+// if the identifiers of the item are unchanged.
+func (n *node) setItem(index int, child *node, identifiers [][]string) {
+	previous := n.items[index]
+	n.items[index] = child
+
+	if previous.itemID(identifiers) != child.itemID(identifiers) {
+		n.index.Store(nil)
+	}
+	if n.vids.Load() != nil && (previous.kind != child.kind || previous.validationID(identifiers) != child.validationID(identifiers)) {
+		n.vids.Store(nil)
+	}
+}
+
+// JSON serializes the node like json-patch does. Raw values are compacted and
+// built containers are serialized with sorted keys.
+func (n *node) JSON() []byte {
+	switch {
+	case n.kind == kindInvalid:
+		return n.raw
+	case n.raw != nil && !n.isContainer():
+		return compactValue(n.raw)
+	}
+
+	if cached := n.json.Load(); cached != nil {
+		return *cached
+	}
+
+	var result []byte
+	switch {
+	case n.raw != nil:
+		result, _ = json.Marshal(json.RawMessage(n.raw))
+	case n.kind == kindObject:
+		result = append(result, '{')
+		for i, key := range n.keys {
+			if i > 0 {
+				result = append(result, ',')
+			}
+			result = appendKey(result, key)
+			result = append(result, ':')
+			result = append(result, n.fields[key].JSON()...)
+		}
+		result = append(result, '}')
+	case n.kind == kindArray:
+		result = append(result, '[')
+		for i, item := range n.items {
+			if i > 0 {
+				result = append(result, ',')
+			}
+			result = append(result, item.JSON()...)
+		}
+		result = append(result, ']')
+	default:
+		result = n.raw
+	}
+
+	n.json.Store(&result)
+	return result
+}
+
+// compactValue compacts a raw value with html escaping like encoding/json.
+func compactValue(raw []byte) []byte {
+	if raw[0] != '"' || (!bytes.ContainsAny(raw, "<>&") && !bytes.Contains(raw, []byte("\u2028")) && !bytes.Contains(raw, []byte("\u2029"))) {
+		return raw
+	}
+
+	result, _ := json.Marshal(json.RawMessage(raw))
+	return result
+}
+
+// appendKey appends an object key like encoding/json encodes map keys.
+func appendKey(dst []byte, key string) []byte {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			encoded, _ := json.Marshal(key)
+			return append(dst, encoded...)
+		}
+	}
+
+	dst = append(dst, '"')
+	dst = append(dst, key...)
+	return append(dst, '"')
+}
+
+// itemID returns the identifier of an array item, like findID does on the raw item.
+func (n *node) itemID(identifiers [][]string) string {
+	if cached := n.id.Load(); cached != nil {
+		return *cached
+	}
+
+	id := ""
+identifiers:
+	for _, identifier := range identifiers {
+		current := n
+		for _, key := range identifier {
+			child, ok := current.field(key)
+			if !ok {
+				continue identifiers
+			}
+			current = child
+		}
+
+		switch current.kind {
+		case kindString:
+			id = string(current.raw[1 : len(current.raw)-1])
+		case kindNumber:
+			id = string(current.raw)
+		default:
+			// other values aren't identifiers
+		}
+		break
+	}
+
+	n.id.Store(&id)
+	return id
+}
+
+// validationID returns the identifier of an array item, like getID does on the
+// decoded item.
+func (n *node) validationID(identifiers [][]string) string {
+	if cached := n.vid.Load(); cached != nil {
+		return *cached
+	}
+
+	id := ""
+identifiers:
+	for _, identifier := range identifiers {
+		current := n
+		for i, key := range identifier {
+			child, ok := current.field(key)
+			if !ok {
+				continue identifiers
+			}
+
+			if i < len(identifier)-1 {
+				if child.kind != kindObject {
+					continue identifiers
+				}
+				current = child
+				continue
+			}
+
+			switch child.kind {
+			case kindNumber:
+				v, _ := strconv.ParseFloat(string(child.raw), 64)
+				if v == float64(int64(v)) {
+					id = formatID(strconv.FormatInt(int64(v), 10))
+				} else {
+					id = formatID(strconv.FormatFloat(v, 'f', 6, 64))
+				}
+			case kindString:
+				var s string
+				_ = json.Unmarshal(child.raw, &s)
+				id = formatID(s)
+			default:
+				// other values aren't identifiers
+			}
+			break identifiers
+		}
+	}
+
+	n.vid.Store(&id)
+	return id
+}
+
+// indexOf returns the position of the last item with the identifier.
+func (n *node) indexOf(id string, identifiers [][]string) (int, bool) {
+	index := n.index.Load()
+	if index == nil {
+		_, items := n.children()
+		built := make(map[string]int, len(items))
+		for i, item := range items {
+			built[item.itemID(identifiers)] = i
+		}
+		index = &built
+		n.index.Store(index)
+	}
+
+	position, ok := (*index)[id]
+	return position, ok
+}
+
+// validationIndex returns the positions of the object items by their
+// validationID. Identifiers of several items have the position -1.
+func (n *node) validationIndex(identifiers [][]string) map[string]int {
+	if index := n.vids.Load(); index != nil {
+		return *index
+	}
+
+	_, items := n.children()
+	index := make(map[string]int, len(items))
+	for i, item := range items {
+		if item.kind != kindObject {
+			continue
+		}
+
+		id := item.validationID(identifiers)
+		if _, ok := index[id]; ok {
+			index[id] = -1
+		} else {
+			index[id] = i
+		}
+	}
+
+	n.vids.Store(&index)
+	return index
+}
+
+// parseView splits a valid raw container into its children.
+func parseView(raw []byte, kind nodeKind) *nodeView {
+	view := &nodeView{}
+	if kind == kindObject {
+		view.fields = map[string]*node{}
+	} else {
+		view.items = []*node{}
+	}
+
+	i := skipSpace(raw, 1)
+	for i < len(raw) && raw[i] != '}' && raw[i] != ']' {
+		key := ""
+		if kind == kindObject {
+			end := scanString(raw, i)
+			key = decodeKey(raw[i:end])
+			i = skipSpace(raw, end) + 1
+			i = skipSpace(raw, i)
+		}
+
+		end := scanValue(raw, i)
+		child := newNode(raw[i:end])
+		if kind == kindObject {
+			view.fields[key] = child
+		} else {
+			view.items = append(view.items, child)
+		}
+
+		i = skipSpace(raw, end)
+		if raw[i] == ',' {
+			i = skipSpace(raw, i+1)
+		}
+	}
+
+	return view
+}
+
+func decodeKey(token []byte) string {
+	content := token[1 : len(token)-1]
+	if bytes.IndexByte(content, '\\') < 0 && utf8.Valid(content) {
+		return string(content)
+	}
+
+	var key string
+	_ = json.Unmarshal(token, &key)
+	return key
+}
+
+func skipSpace(raw []byte, i int) int {
+	for i < len(raw) && (raw[i] == ' ' || raw[i] == '\t' || raw[i] == '\n' || raw[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// scanString returns the end of the string starting at i.
+func scanString(raw []byte, i int) int {
+	for i++; raw[i] != '"'; i++ {
+		if raw[i] == '\\' {
+			i++
+		}
+	}
+	return i + 1
+}
+
+// scanValue returns the end of the value starting at i.
+func scanValue(raw []byte, i int) int {
+	switch raw[i] {
+	case '"':
+		return scanString(raw, i)
+	case '{', '[':
+		depth := 0
+		for {
+			switch raw[i] {
+			case '"':
+				i = scanString(raw, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+	}
+
+	for i < len(raw) && raw[i] != ',' && raw[i] != '}' && raw[i] != ']' && raw[i] != ' ' && raw[i] != '\t' && raw[i] != '\n' && raw[i] != '\r' {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,114 @@
+package pigeongo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	jsonpatch "gopkg.in/evanphx/json-patch.v5"
+)
+
+// jsonpatchApply applies the operations one by one with json-patch on the raw bytes.
+func jsonpatchApply(doc []byte, operations []Operation) ([]byte, error) {
+	for _, operation := range operations {
+		raw, err := json.Marshal([]Operation{operation})
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := jsonpatch.DecodePatch(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err = p.Apply(doc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func TestPatchTreeMatchesJsonpatch(t *testing.T) {
+	t.Parallel()
+
+	// paths behind the last array element are not compared, they are rewritten
+	// to `-` before json-patch is applied
+	testCases := []struct {
+		doc        string
+		operations string
+	}{
+		{`{ "b": 1, "a": { "d": 2, "c": 3 } }`, `[{"op":"replace","path":"/b","value":2}]`},
+		{`{ "b": 1, "a": { "d": 2, "c": 3 } }`, `[{"op":"replace","path":"/a/c","value":4}]`},
+		{`{ "b": "<&>", "a": { "d": " " } }`, `[{"op":"add","path":"/c","value":"<b>"}]`},
+		{`{ "a": [1, 2, 3] }`, `[{"op":"add","path":"/a/-","value":4},{"op":"remove","path":"/a/0"}]`},
+		{`{ "a": [1, 2, 3] }`, `[{"op":"add","path":"/a/-1","value":4}]`},
+		{`{ "a": { "b": 1 }, "c": {} }`, `[{"op":"move","from":"/a/b","path":"/c/b"}]`},
+		{`{ "a": { "b": 1 }, "c": {} }`, `[{"op":"move","from":"/a/x","path":"/c/b"}]`},
+		{`{ "a": { "b": [1] }, "c": {} }`, `[{"op":"copy","from":"/a/b","path":"/c/b"},{"op":"add","path":"/c/b/-","value":2}]`},
+		{`{ "a": { "y": 1, "x": { "q": 2, "p": 3 } }, "c": {} }`, `[{"op":"copy","from":"/a","path":"/c/b"}]`},
+		{`{ "a": { "y": 1, "x": { "q": 2, "p": 3 } } }`, `[{"op":"copy","from":"/a","path":"/a/z"}]`},
+		{`{ "a": { "y": 1, "x": { "q": 2, "p": 3 } } }`, `[{"op":"copy","from":"/a","path":"/a/x/z"}]`},
+		{`{ "a": { "y": { "q": 2, "p": 3 } } }`, `[{"op":"copy","from":"/a/y","path":"/a/y/z"}]`},
+		{`{ "a": [{ "y": 1, "x": 2 }] }`, `[{"op":"copy","from":"/a","path":"/a/0/z"}]`},
+		{`{ "a": [{ "y": 1, "x": 2 }] }`, `[{"op":"copy","from":"/a/0","path":"/a/-"}]`},
+		{`{ "a": { "y": "<&>", "x": " " } }`, `[{"op":"copy","from":"/a","path":"/b"}]`},
+		{`{ "a": { "b": 1 } }`, `[{"op":"test","path":"/a/b","value":1}]`},
+		{`{ "a": { "b": 1 } }`, `[{"op":"test","path":"/a/b","value":2}]`},
+		{`{ "a": null }`, `[{"op":"add","path":"/a/b","value":1}]`},
+		{`{ "a": 1 }`, `[{"op":"remove","path":"/b"}]`},
+		{`{ "a": 1 }`, `[{"op":"replace","path":"/b","value":2}]`},
+		{`{ "a/b": 1, "c~d": 2 }`, `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/c~0d","value":3}]`},
+		{`[1, {"b": 2, "a": 1}]`, `[{"op":"add","path":"/0","value":0}]`},
+		{`{ "a": 1 }`, `[{"op":"unknown","path":"/a"}]`},
+	}
+
+	for _, testCase := range testCases {
+		var operations []Operation
+		assert.NoError(t, json.Unmarshal([]byte(testCase.operations), &operations))
+
+		want, wantErr := jsonpatchApply([]byte(testCase.doc), operations)
+		got, gotErr := patch([]byte(testCase.doc), operations, [][]string{{"id"}})
+
+		if wantErr != nil {
+			// error strings are lower case, json-patch capitalizes some
+			if assert.Error(t, gotErr, testCase.operations) {
+				assert.True(t, strings.EqualFold(wantErr.Error(), gotErr.Error()), "%s: %s != %s", testCase.operations, wantErr, gotErr)
+			}
+			continue
+		}
+
+		assert.NoError(t, gotErr, testCase.operations)
+		assert.Equal(t, string(want), string(got), testCase.operations)
+	}
+}
+
+func TestPatchTreeSharesUntouchedNodes(t *testing.T) {
+	t.Parallel()
+
+	root := parseRoot([]byte(`{ "b": { "y": 1, "x": 2 }, "a": [{ "id": "c1" }, { "id": "c2" }] }`))
+	identifiers := [][]string{{"id"}}
+
+	patched, _, err := patchTree(root, []Operation{
+		{Op: "replace", Path: "/a/[c2]/id", Value: rawMessage(`"c3"`)},
+	}, identifiers)
+	assert.NoError(t, err)
+
+	before, _ := root.field("b")
+	after, _ := patched.field("b")
+	assert.Same(t, before, after)
+	assert.Equal(t, `{"y":1,"x":2}`, string(after.JSON()))
+	assert.Equal(t, `{"a":[{"id":"c1"},{"id":"c3"}],"b":{"y":1,"x":2}}`, string(patched.JSON()))
+
+	array, _ := patched.field("a")
+	_, ok := array.indexOf("c2", identifiers)
+	assert.False(t, ok)
+	position, ok := array.indexOf("c3", identifiers)
+	assert.True(t, ok)
+	assert.Equal(t, 1, position)
+
+	// the previous version is unchanged
+	assert.Equal(t, `{"b":{"y":1,"x":2},"a":[{"id":"c1"},{"id":"c2"}]}`, string(root.JSON()))
+}
@@ -0,0 +1,122 @@
+package pigeongo
+
+import (
+	"errors"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// undoState is the undo and redo state of a client.
+type undoState struct {
+	// skip contains undone changes and the changes that undid them
+	skip map[string]bool
+	// redo contains the undone changes, the newest last
+	redo []Change
+	// last is the change id of the last undo or redo
+	last string
+}
+
+func (s *undoState) clone() *undoState {
+	clone := &undoState{
+		skip: make(map[string]bool, len(s.skip)),
+		redo: make([]Change, len(s.redo)),
+		last: s.last,
+	}
+
+	for changeID := range s.skip {
+		clone.skip[changeID] = true
+	}
+	copy(clone.redo, s.redo)
+
+	return clone
+}
+
+// Undo reverts the latest change of the client in the history, that isn't undone
+// yet. Changes of other clients are kept. The compensating change is applied to
+// the document and returned ready to broadcast.
+func (d *Document) Undo(clientID string) (Change, error) {
+	state := d.undoState(clientID)
+
+	var undone *Change
+	for i := len(d.history) - 1; i > 0; i-- {
+		if d.history[i].ClientID == clientID && !state.skip[d.history[i].ChangeID] {
+			undone = &d.history[i]
+			break
+		}
+	}
+
+	if undone == nil {
+		return Change{}, ErrNothingToUndo
+	}
+
+	// the previous values are set by the current state, the tests of the undone
+	// change are no preconditions of the compensating change
+	change := d.stamp(clientID, withoutTests(reverse(undone.Diff, d.identifiers)))
+	original := *undone
+	if err := d.applyOwnChange(&change); err != nil {
+		return Change{}, err
+	}
+
+	state.skip[original.ChangeID] = true
+	state.skip[change.ChangeID] = true
+	state.redo = append(state.redo, original)
+	state.last = change.ChangeID
+
+	return change, nil
+}
+
+// Redo applies the latest undone change of the client again. It is only possible
+// directly after Undo or Redo, other changes of the client clear the redo stack.
+// The change is applied to the document and returned ready to broadcast.
+func (d *Document) Redo(clientID string) (Change, error) {
+	state := d.undoState(clientID)
+
+	if len(state.redo) == 0 {
+		return Change{}, ErrNothingToRedo
+	}
+
+	// the client changed the document after the last undo or redo
+	if d.lastChangeID(clientID) != state.last {
+		state.redo = nil
+		return Change{}, ErrNothingToRedo
+	}
+
+	redo := state.redo[len(state.redo)-1]
+
+	operations := make([]Operation, len(redo.Diff))
+	copy(operations, redo.Diff)
+
+	change := d.stamp(clientID, operations)
+	if err := d.applyOwnChange(&change); err != nil {
+		return Change{}, err
+	}
+
+	state.redo = state.redo[:len(state.redo)-1]
+	state.last = change.ChangeID
+
+	return change, nil
+}
+
+func (d *Document) undoState(clientID string) *undoState {
+	state, ok := d.undo[clientID]
+	if !ok {
+		state = &undoState{skip: map[string]bool{}}
+		d.undo[clientID] = state
+	}
+
+	return state
+}
+
+// lastChangeID returns the id of the latest change of a client in the history.
+func (d *Document) lastChangeID(clientID string) string {
+	for i := len(d.history) - 1; i > 0; i-- {
+		if d.history[i].ClientID == clientID {
+			return d.history[i].ChangeID
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,101 @@
+package pigeongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoRedo(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1000)
+	doc, err := NewDocument([]byte(`{"name":"Philipp","title":"Board","cards":[{"id":"card1","text":"foo"}]}`), WithClock(func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}))
+	assert.Nil(t, err)
+
+	_, err = doc.Undo("client1")
+	assert.Equal(t, ErrNothingToUndo, err)
+
+	_, err = doc.Commit("client1", []byte(`{"name":"Phil","title":"Board","cards":[{"id":"card1","text":"foo"}]}`))
+	assert.Nil(t, err)
+	_, err = doc.Commit("client1", []byte(`{"name":"Phil","title":"Board","cards":[{"id":"card1","text":"foo"},{"id":"card2","text":"bar"}]}`))
+	assert.Nil(t, err)
+	_, err = doc.Commit("client2", []byte(`{"name":"Phil","title":"Kanban","cards":[{"id":"card1","text":"foo"},{"id":"card2","text":"bar"}]}`))
+	assert.Nil(t, err)
+
+	// undo only the changes of the client
+	change, err := doc.Undo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, "client1", change.ClientID)
+	assert.Equal(t, 3, change.Seq)
+	assert.Equal(t, []Operation{{Op: "remove", Path: "/cards/[card2]", Prev: rawMessage(`{"id":"card2","text":"bar"}`), Anchor: "/cards/1"}}, change.Diff)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Phil","title":"Kanban"}`, string(doc.JSON()))
+
+	change, err = doc.Undo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, []Operation{{Op: "replace", Path: "/name", Value: rawMessage(`"Philipp"`), Prev: rawMessage(`"Phil"`)}}, change.Diff)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Philipp","title":"Kanban"}`, string(doc.JSON()))
+
+	_, err = doc.Undo("client1")
+	assert.Equal(t, ErrNothingToUndo, err)
+
+	// redo in reverse order
+	_, err = doc.Redo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Phil","title":"Kanban"}`, string(doc.JSON()))
+
+	// changes of other clients don't clear the redo stack
+	_, err = doc.Commit("client2", []byte(`{"name":"Phil","title":"Kanban","cards":[{"id":"card1","text":"baz"}]}`))
+	assert.Nil(t, err)
+
+	_, err = doc.Redo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"},{"id":"card2","text":"bar"}],"name":"Phil","title":"Kanban"}`, string(doc.JSON()))
+
+	_, err = doc.Redo("client1")
+	assert.Equal(t, ErrNothingToRedo, err)
+
+	// a redo can be undone
+	_, err = doc.Undo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"}],"name":"Phil","title":"Kanban"}`, string(doc.JSON()))
+
+	// a new change of the client clears the redo stack
+	_, err = doc.Commit("client1", []byte(`{"name":"Hans","title":"Kanban","cards":[{"id":"card1","text":"baz"}]}`))
+	assert.Nil(t, err)
+	_, err = doc.Redo("client1")
+	assert.Equal(t, ErrNothingToRedo, err)
+
+	// the clone has its own undo state
+	clone := doc.Clone()
+	_, err = clone.Undo("client1")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"}],"name":"Hans","title":"Kanban"}`, string(doc.JSON()))
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"baz"}],"name":"Phil","title":"Kanban"}`, string(clone.JSON()))
+}
+
+func TestUndoConflict(t *testing.T) {
+	t.Parallel()
+
+	now := time.UnixMilli(1000)
+	doc, err := NewDocument([]byte(`{"cards":[{"id":"card1","text":"foo"}]}`), WithClock(func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}))
+	assert.Nil(t, err)
+
+	_, err = doc.Commit("client1", []byte(`{"cards":[{"id":"card1","text":"bar"}]}`))
+	assert.Nil(t, err)
+	_, err = doc.Commit("client2", []byte(`{"cards":[]}`))
+	assert.Nil(t, err)
+
+	// the card was removed by another client
+	_, err = doc.Undo("client1")
+	assert.NotNil(t, err)
+	assert.Equal(t, `{"cards":[]}`, string(doc.JSON()))
+	assert.Len(t, doc.History(), 3)
+}
@@ -0,0 +1,106 @@
+package pigeongo
+
+import "fmt"
+
+// Phases of a warning.
+const (
+	PhaseRewind      = "rewind"
+	PhasePatch       = "patch"
+	PhaseFastForward = "forward"
+)
+
+// WithLenientApply skips failing operations instead of failing the whole change,
+// like PigeonJS `applyChanges`. Skipped operations are removed from the change
+// and reported by Warnings. A change that results in duplicate identifiers is
+// skipped completely.
+func WithLenientApply() DocumentOption {
+	return func(d *Document) {
+		d.lenient = true
+	}
+}
+
+// Warning describes an operation or change, that was skipped in lenient mode.
+type Warning struct {
+	ChangeID string
+	Phase    string
+	// OpIndex is the index of the operation in the change or -1 if the whole change was skipped.
+	OpIndex int
+	Err     error
+}
+
+func (w Warning) String() string {
+	if w.OpIndex < 0 {
+		return fmt.Sprintf("%s failed: changeID %s: %s", w.Phase, w.ChangeID, w.Err.Error())
+	}
+
+	return fmt.Sprintf("%s failed: changeID %s operation %d: %s", w.Phase, w.ChangeID, w.OpIndex, w.Err.Error())
+}
+
+// Warnings returns the warnings of the last call, that applied changes in lenient
+// mode or with checksums. Released pending changes, that fail, are dropped and
+// reported, too.
+func (d *Document) Warnings() []Warning {
+	return d.warnings
+}
+
+func (d *Document) warn(changeID, phase string, opIndex int, err error) {
+	d.warnings = append(d.warnings, Warning{
+		ChangeID: changeID,
+		Phase:    phase,
+		OpIndex:  opIndex,
+		Err:      err,
+	})
+}
+
+// applyOperations patches the document and returns the operations with resolved
+// index paths. In lenient mode failing operations are skipped and the indexes of
+// the skipped operations are returned. If the operations are reversed, the
+// indexes refer to the original operations.
+func (d *Document) applyOperations(changeID, phase string, operations []Operation, reversed bool) ([]Operation, []int, error) {
+	if !d.lenient {
+		resolved, err := d.patch(operations)
+		if err != nil {
+			return nil, nil, err
+		}
+		return resolved, nil, nil
+	}
+
+	resolved := make([]Operation, 0, len(operations))
+	skipped := []int{}
+	for i, operation := range operations {
+		resolvedOperation, err := d.patch([]Operation{operation})
+		if err != nil {
+			index := i
+			if reversed {
+				index = len(operations) - 1 - i
+			}
+			d.warn(changeID, phase, index, err)
+			skipped = append(skipped, index)
+			continue
+		}
+		resolved = append(resolved, resolvedOperation...)
+	}
+
+	return resolved, skipped, nil
+}
+
+// withoutOperations removes the skipped operations.
+func withoutOperations(operations []Operation, skipped []int) []Operation {
+	if len(skipped) == 0 {
+		return operations
+	}
+
+	isSkipped := map[int]bool{}
+	for _, index := range skipped {
+		isSkipped[index] = true
+	}
+
+	result := make([]Operation, 0, len(operations)-len(skipped))
+	for i, operation := range operations {
+		if !isSkipped[i] {
+			result = append(result, operation)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,170 @@
+package pigeongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLenientApply(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"name":"Philipp","cards":[{"id":"card1","text":"foo"}]}`), WithLenientApply())
+	assert.Nil(t, err)
+
+	// skip failing operation
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/name",
+				Value: rawMessage(`"Phil"`),
+			},
+			{
+				Op:   "remove",
+				Path: "/notexist",
+			},
+		},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[{"id":"card1","text":"foo"}],"name":"Phil"}`, string(doc.JSON()))
+	assert.Len(t, doc.Warnings(), 1)
+	assert.Equal(t, "change1", doc.Warnings()[0].ChangeID)
+	assert.Equal(t, PhasePatch, doc.Warnings()[0].Phase)
+	assert.Equal(t, 1, doc.Warnings()[0].OpIndex)
+	assert.Equal(t, "patch failed: changeID change1 operation 1: error in remove for path: '/notexist': unable to remove nonexistent key: notexist: missing value", doc.Warnings()[0].String())
+	assert.Len(t, doc.History()[1].Diff, 1)
+
+	// warnings are reset by the next change
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "replace",
+				Path:  "/cards/[card1]/text",
+				Value: rawMessage(`"bar"`),
+			},
+		},
+		TimestampMillis: 20,
+		ClientID:        "client1",
+		ChangeID:        "change2",
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, doc.Warnings())
+
+	// skip changes of the fast forward
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:   "remove",
+				Path: "/cards/[card1]",
+			},
+		},
+		TimestampMillis: 15,
+		ClientID:        "client2",
+		ChangeID:        "change3",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[],"name":"Phil"}`, string(doc.JSON()))
+	assert.Equal(t, []Warning{
+		{
+			ChangeID: "change2",
+			Phase:    PhaseFastForward,
+			OpIndex:  0,
+			Err:      doc.Warnings()[0].Err,
+		},
+	}, doc.Warnings())
+	assert.Equal(t, "change2", doc.History()[3].ChangeID)
+	assert.Empty(t, doc.History()[3].Diff)
+
+	// skip change with duplicate identifiers
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/cards/0",
+				Value: rawMessage(`{"id":"card2"}`),
+			},
+			{
+				Op:    "add",
+				Path:  "/cards/0",
+				Value: rawMessage(`{"id":"card2"}`),
+			},
+		},
+		TimestampMillis: 30,
+		ClientID:        "client1",
+		ChangeID:        "change4",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cards":[],"name":"Phil"}`, string(doc.JSON()))
+	assert.Len(t, doc.Warnings(), 1)
+	assert.Equal(t, -1, doc.Warnings()[0].OpIndex)
+	assert.Equal(t, "patch failed: changeID change4: duplicate identifier found: id `[card2]` at path /cards/1", doc.Warnings()[0].String())
+	assert.Len(t, doc.History(), 4)
+	assert.NotContains(t, doc.changeIDs, "change4")
+}
+
+func TestLenientApplyRewind(t *testing.T) {
+	t.Parallel()
+
+	doc, err := NewDocument([]byte(`{"items":[1,2]}`), WithLenientApply())
+	assert.Nil(t, err)
+
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/items/-",
+				Value: rawMessage(`3`),
+			},
+		},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	})
+	assert.Nil(t, err)
+
+	// the end of array can't be reversed, the value is kept
+	err = doc.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/name",
+				Value: rawMessage(`"list"`),
+			},
+		},
+		TimestampMillis: 5,
+		ClientID:        "client1",
+		ChangeID:        "change2",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"items":[1,2,3],"name":"list"}`, string(doc.JSON()))
+	assert.Len(t, doc.Warnings(), 1)
+	assert.Equal(t, PhaseRewind, doc.Warnings()[0].Phase)
+	assert.Equal(t, "change1", doc.Warnings()[0].ChangeID)
+
+	// strict mode fails
+	strict, err := NewDocument([]byte(`{"items":[1,2]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, strict.ApplyChange(Change{
+		Diff: []Operation{
+			{
+				Op:    "add",
+				Path:  "/items/-",
+				Value: rawMessage(`3`),
+			},
+		},
+		TimestampMillis: 10,
+		ClientID:        "client1",
+		ChangeID:        "change1",
+	}))
+	assert.NotNil(t, strict.ApplyChange(Change{
+		Diff:            []Operation{},
+		TimestampMillis: 5,
+		ClientID:        "client1",
+		ChangeID:        "change2",
+	}))
+	assert.Empty(t, strict.Warnings())
+}